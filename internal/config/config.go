@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -11,12 +14,48 @@ var (
 	JWTSecret string
 	Port      string
 
+	// Token 有效期：access token 短期有效，通过 refresh token 续期
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// 登录防暴力破解：连续失败达到阈值后锁定一段时间
+	LoginMaxFailures     int
+	LoginIPMaxFailures   int
+	LoginLockoutDuration time.Duration
+
+	// 首个平台管理员（super_admin）：系统中还没有平台管理员且设置了初始密码时，启动时创建
+	SuperAdminUsername string
+	SuperAdminPassword string
+
+	// 审计哈希链检查点：签名密钥（必须单独配置，不复用 JWT_SECRET；未配置时不生成检查点）和生成间隔
+	AuditSigningKey         string
+	AuditCheckpointInterval time.Duration
+
 	// MinIO 配置
 	MinIOEndpoint        string
 	MinIOAccessKeyID     string
 	MinIOSecretAccessKey string
 	MinIOUseSSL          bool
 	MinIOBucketName      string
+
+	// 寄存凭条 / 行李标签打印配置
+	TicketFontPath string
+
+	// 客人通知：通知中的二维码链接前缀，未配置时使用相对路径 /qr/<code>
+	PublicBaseURL string
+
+	// 邮件通知（SMTP），未配置 SMTP_HOST 时不发送邮件
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// 短信通知（通用 webhook，POST JSON 给短信网关），未配置 SMS_WEBHOOK_URL 时不发送短信
+	SMSWebhookURL   string
+	SMSWebhookToken string
+
+	NotificationTimeout time.Duration
 )
 
 func Init() {
@@ -30,6 +69,29 @@ func Init() {
 		JWTSecret = "your-secret-key"
 	}
 
+	AccessTokenTTL = durationFromEnv("ACCESS_TOKEN_TTL", 2*time.Hour)
+	RefreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+
+	LoginMaxFailures = intFromEnv("LOGIN_MAX_FAILURES", 5)
+	LoginIPMaxFailures = intFromEnv("LOGIN_IP_MAX_FAILURES", 20)
+	LoginLockoutDuration = durationFromEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+
+	SuperAdminUsername = os.Getenv("SUPER_ADMIN_USERNAME")
+	if SuperAdminUsername == "" {
+		SuperAdminUsername = "superadmin"
+	}
+	SuperAdminPassword = os.Getenv("SUPER_ADMIN_PASSWORD")
+
+	AuditSigningKey = os.Getenv("AUDIT_SIGNING_KEY")
+	if AuditSigningKey != "" && AuditSigningKey == JWTSecret {
+		log.Printf("Warning: AUDIT_SIGNING_KEY must differ from JWT_SECRET, ignoring it")
+		AuditSigningKey = ""
+	}
+	if AuditSigningKey == "" {
+		log.Printf("Warning: AUDIT_SIGNING_KEY is not set, audit chain checkpoints will not be created or verified")
+	}
+	AuditCheckpointInterval = durationFromEnv("AUDIT_CHECKPOINT_INTERVAL", time.Hour)
+
 	Port = os.Getenv("PORT")
 	if Port == "" {
 		Port = "10.154.39.253:8080"
@@ -69,7 +131,55 @@ func Init() {
 		MinIOBucketName = "training-hotel" // 默认桶名
 	}
 	
+	// 中文字体（TTF）路径，未配置时使用 PDF 内置字体，非拉丁字符无法显示
+	TicketFontPath = os.Getenv("TICKET_FONT_PATH")
+
+	// 客人通知渠道
+	PublicBaseURL = strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	SMTPHost = os.Getenv("SMTP_HOST")
+	SMTPPort = os.Getenv("SMTP_PORT")
+	if SMTPPort == "" {
+		SMTPPort = "587"
+	}
+	SMTPUsername = os.Getenv("SMTP_USERNAME")
+	SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	SMTPFrom = os.Getenv("SMTP_FROM")
+	if SMTPFrom == "" {
+		SMTPFrom = SMTPUsername
+	}
+	SMSWebhookURL = os.Getenv("SMS_WEBHOOK_URL")
+	SMSWebhookToken = os.Getenv("SMS_WEBHOOK_TOKEN")
+	NotificationTimeout = durationFromEnv("NOTIFICATION_TIMEOUT", 10*time.Second)
+
 	// 打印 MinIO 配置（用于调试）
 	fmt.Printf("MinIO Config: endpoint=%s, bucket=%s, accessKey=%s, useSSL=%v\n", 
 		MinIOEndpoint, MinIOBucketName, MinIOAccessKeyID, MinIOUseSSL)
 }
+
+// durationFromEnv 读取时长配置（如 "30m"、"2h"），未设置或格式错误时使用默认值
+func durationFromEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s=%q, using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// intFromEnv 读取正整数配置，未设置或格式错误时使用默认值
+func intFromEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: invalid %s=%q, using default %d", key, v, def)
+		return def
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// chainBatchSize 为历史审计事件补链时每个事务处理的条数
+const chainBatchSize = 500
+
+// LockAuditChainHead 在事务内锁定酒店的哈希链头（不存在时创建），同一酒店的审计事件在锁内按序追加。
+// 调用方应在事务的最后一步获取，缩短持锁时间并避免与业务行锁交叉等待
+func LockAuditChainHead(tx *gorm.DB, hotelID uint) (*models.AuditChainHead, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.AuditChainHead{HotelID: hotelID}).Error; err != nil {
+		return nil, err
+	}
+	var head models.AuditChainHead
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("hotel_id = ?", hotelID).
+		First(&head).Error; err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// AppendAuditEvents 把审计事件接到酒店哈希链末尾：依次分配序号、记录上一条哈希并计算本条哈希，写入后更新链头
+func AppendAuditEvents(tx *gorm.DB, hotelID uint, events []models.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	head, err := LockAuditChainHead(tx, hotelID)
+	if err != nil {
+		return err
+	}
+
+	now := models.AuditTime(time.Now())
+	for i := range events {
+		events[i].HotelID = hotelID
+		events[i].CreatedAt = now
+		linkAuditEvent(head, &events[i])
+	}
+	if err := tx.Create(&events).Error; err != nil {
+		return err
+	}
+	return saveAuditChainHead(tx, head)
+}
+
+// linkAuditEvent 把事件接在链头之后并前移链头
+func linkAuditEvent(head *models.AuditChainHead, event *models.AuditEvent) {
+	event.Seq = head.Seq + 1
+	event.PrevHash = head.Hash
+	event.Hash = event.ComputeHash()
+	head.Seq = event.Seq
+	head.Hash = event.Hash
+}
+
+func saveAuditChainHead(tx *gorm.DB, head *models.AuditChainHead) error {
+	return tx.Model(&models.AuditChainHead{}).
+		Where("hotel_id = ?", head.HotelID).
+		Updates(map[string]interface{}{"seq": head.Seq, "hash": head.Hash, "updated_at": time.Now()}).Error
+}
+
+// chainAuditEvents 一次性迁移：为还没有哈希链头的酒店，把升级前写入或从历史日志迁移的事件（seq 为 0）按 ID 顺序补链。
+// 已有链头的酒店不再补链，之后出现的 seq 为 0 的事件只能是绕过应用写入的，由校验报告为 unchained_event
+func chainAuditEvents() {
+	var hotelIDs []uint
+	if err := DB.Model(&models.AuditEvent{}).
+		Where("seq = 0 AND hotel_id NOT IN (?)", DB.Model(&models.AuditChainHead{}).Select("hotel_id")).
+		Distinct().
+		Pluck("hotel_id", &hotelIDs).Error; err != nil {
+		log.Printf("Failed to chain audit events: %v", err)
+		return
+	}
+
+	for _, hotelID := range hotelIDs {
+		chained := 0
+		for {
+			n, err := chainAuditBatch(hotelID)
+			if err != nil {
+				log.Printf("Failed to chain audit events for hotel %d: %v", hotelID, err)
+				break
+			}
+			chained += n
+			if n < chainBatchSize {
+				break
+			}
+		}
+		if chained > 0 {
+			log.Printf("Chained %d audit events for hotel %d", chained, hotelID)
+		}
+	}
+}
+
+// chainAuditBatch 补链一批事件；审计事件不允许通过模型更新，这里跳过钩子只写入链字段
+func chainAuditBatch(hotelID uint) (int, error) {
+	n := 0
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		head, err := LockAuditChainHead(tx, hotelID)
+		if err != nil {
+			return err
+		}
+
+		var events []models.AuditEvent
+		if err := tx.Where("hotel_id = ? AND seq = 0", hotelID).
+			Order("id ASC").
+			Limit(chainBatchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		for i := range events {
+			linkAuditEvent(head, &events[i])
+			if err := tx.Session(&gorm.Session{SkipHooks: true}).
+				Model(&events[i]).
+				UpdateColumns(map[string]interface{}{
+					"seq":       events[i].Seq,
+					"prev_hash": events[i].PrevHash,
+					"hash":      events[i].Hash,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		n = len(events)
+		if n == 0 {
+			return nil
+		}
+		return saveAuditChainHead(tx, head)
+	})
+	return n, err
+}
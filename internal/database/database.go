@@ -1,8 +1,11 @@
 package database
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"sort"
 
 	"luggage-sys2/internal/config"
 	"luggage-sys2/internal/models"
@@ -15,7 +18,8 @@ import (
 
 var DB *gorm.DB
 
-func Init() {
+// Connect 只连接数据库并注册租户校验，不迁移、不写入任何数据；供 verify-audit 等只读命令使用
+func Connect() {
 	var err error
 	DB, err = gorm.Open(mysql.Open(config.DBDSN), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
@@ -24,50 +28,357 @@ func Init() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// 多租户表必须带酒店条件
+	if err := registerTenantGuard(DB); err != nil {
+		log.Fatal("Failed to register tenant guard:", err)
+	}
+}
+
+// Init 连接数据库并执行迁移、补齐历史数据，服务启动时调用
+func Init() {
+	Connect()
+
 	// 修复取件码索引：先删除唯一索引（如果存在），再执行迁移
 	fixRetrievalCodeIndex()
 
+	// 房号改为 NOT NULL：迁移前先把历史 NULL 值补成空字符串
+	backfillLuggageRoomNumber()
+
 	// 自动迁移
-	err = DB.AutoMigrate(
+	err := DB.AutoMigrate(
+		&models.Hotel{},
 		&models.User{},
 		&models.Luggage{},
 		&models.Storeroom{},
+		&models.StorageSlot{},
 		&models.StoredLog{},
 		&models.UpdatedLog{},
 		&models.RetrievedLog{},
+		&models.MovedLog{},
+		&models.AuditEvent{},
+		&models.AuditChainHead{},
+		&models.AuditCheckpoint{},
+		&models.Session{},
+		&models.RefreshToken{},
+		&models.LoginAttempt{},
+		&models.LoginThrottle{},
+		&models.CheckoutIdempotency{},
+		&models.ActiveRetrievalCode{},
+		&models.NotificationTemplate{},
+		&models.NotificationLog{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// 行李描述 / 特殊说明全文索引，供行李搜索使用
+	ensureLuggageFulltextIndex()
+
 	// 初始化默认数据
 	initDefaultData()
 }
 
 func initDefaultData() {
-	// 创建默认管理员（若不存在）
-	var admin models.User
-	err := DB.Where("username = ?", "admin").First(&admin).Error
+	// 补齐酒店记录（历史数据只有 hotel_id，没有 hotels 表）
+	ensureHotels()
+
+	// 历史行李记录补齐 hotel_id（从所在寄存室获取）
+	backfillLuggageHotelID()
+
+	// 登记仍有在存行李的取件码
+	backfillActiveRetrievalCodes()
+
+	// 历史日志表迁移为审计事件
+	backfillAuditEvents()
+
+	// 升级前的审计事件补齐哈希链（只处理还没有链头的酒店，一次性迁移）
+	chainAuditEvents()
+
+	// 创建默认酒店管理员（若不存在）
+	// 默认密码过于简单，首次登录后必须修改
+	seedUser("admin", models.RoleAdmin, 1)
+
+	// 平台管理员不使用默认密码，只在显式提供初始密码时创建
+	bootstrapSuperAdmin()
+}
+
+// ensureHotels 为已被引用但不存在的 hotel_id 创建酒店记录，没有任何酒店时创建默认酒店
+func ensureHotels() {
+	var hotelIDs []uint
+	DB.Raw(`
+		SELECT hotel_id FROM users WHERE hotel_id > 0
+		UNION
+		SELECT hotel_id FROM storerooms WHERE hotel_id > 0
+	`).Scan(&hotelIDs)
+	if len(hotelIDs) == 0 {
+		hotelIDs = []uint{1}
+	}
+
+	for _, id := range hotelIDs {
+		var count int64
+		DB.Model(&models.Hotel{}).Where("id = ?", id).Count(&count)
+		if count > 0 {
+			continue
+		}
+		hotel := models.Hotel{
+			ID:                    id,
+			Name:                  fmt.Sprintf("Hotel %d", id),
+			Timezone:              models.DefaultHotelTimezone,
+			RetrievalCodeLength:   models.DefaultRetrievalCodeLength,
+			RetrievalCodeAlphabet: models.DefaultRetrievalCodeAlphabet,
+			CheckoutVerification:  models.CheckoutVerifyNone,
+			CheckoutMaxAttempts:   models.DefaultCheckoutMaxAttempts,
+			IsActive:              true,
+		}
+		if err := DB.Create(&hotel).Error; err != nil {
+			log.Printf("Failed to create hotel %d: %v", id, err)
+			continue
+		}
+		log.Printf("Hotel created: id=%d name=%s", hotel.ID, hotel.Name)
+	}
+}
+
+// backfillLuggageHotelID 为 hotel_id 为空的行李记录补齐所属酒店
+func backfillLuggageHotelID() {
+	result := DB.Exec(`
+		UPDATE luggages
+		JOIN storerooms ON luggages.storeroom_id = storerooms.id
+		SET luggages.hotel_id = storerooms.hotel_id
+		WHERE luggages.hotel_id = 0
+	`)
+	if result.Error != nil {
+		log.Printf("Failed to backfill luggage hotel_id: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled hotel_id for %d luggage records", result.RowsAffected)
+	}
+}
+
+// backfillLuggageRoomNumber 把房号为 NULL 的行李记录改为空字符串，需在 AutoMigrate 之前执行
+func backfillLuggageRoomNumber() {
+	var columnExists int
+	DB.Raw("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'luggages' AND column_name = 'room_number'").Scan(&columnExists)
+	if columnExists == 0 {
+		return // 表或列不存在，AutoMigrate 会创建
+	}
+
+	result := DB.Exec("UPDATE luggages SET room_number = '' WHERE room_number IS NULL")
+	if result.Error != nil {
+		log.Printf("Failed to backfill luggage room_number: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Backfilled room_number for %d luggage records", result.RowsAffected)
+	}
+}
+
+// backfillActiveRetrievalCodes 为在存行李的取件码补齐占用记录（已存在的跳过）
+func backfillActiveRetrievalCodes() {
+	result := DB.Exec(`
+		INSERT IGNORE INTO active_retrieval_codes (hotel_id, code, created_at)
+		SELECT DISTINCT hotel_id, retrieval_code, NOW()
+		FROM luggages
+		WHERE status IN ('stored', 'overdue', 'abandoned') AND hotel_id > 0 AND deleted_at IS NULL
+	`)
+	if result.Error != nil {
+		log.Printf("Failed to backfill active retrieval codes: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Registered %d active retrieval codes", result.RowsAffected)
+	}
+}
+
+// backfillAuditEvents audit_events 为空时把历史寄存 / 修改 / 取出 / 移库记录迁移为审计事件，按时间顺序写入
+func backfillAuditEvents() {
+	var count int64
+	if err := DB.Model(&models.AuditEvent{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	var events []models.AuditEvent
+
+	// 旧寄存记录没有经办人，取行李记录的 staff_name
+	var staff []struct {
+		ID        uint
+		StaffName string
+	}
+	DB.Raw("SELECT id, staff_name FROM luggages").Scan(&staff)
+	staffByLuggage := make(map[uint]string, len(staff))
+	for _, row := range staff {
+		staffByLuggage[row.ID] = row.StaffName
+	}
+
+	var stored []models.StoredLog
+	DB.Find(&stored)
+	for _, l := range stored {
+		events = append(events, models.AuditEvent{
+			HotelID:    l.HotelID,
+			Actor:      staffByLuggage[l.LuggageID],
+			Action:     models.AuditLuggageStored,
+			EntityType: models.AuditEntityLuggage,
+			EntityID:   l.LuggageID,
+			EntityName: l.GuestName,
+			Changes:    models.AuditChanges{"status": {New: l.Status}},
+			CreatedAt:  l.StoredAt,
+		})
+	}
+
+	var updated []models.UpdatedLog
+	DB.Find(&updated)
+	for _, l := range updated {
+		var before, after struct {
+			GuestName string `json:"guest_name"`
+			Status    string `json:"status"`
+			Note      string `json:"note"`
+		}
+		_ = json.Unmarshal([]byte(l.OldData), &before)
+		_ = json.Unmarshal([]byte(l.NewData), &after)
+		action := models.AuditLuggageUpdated
+		switch {
+		case l.UpdatedBy == "system":
+			action = models.AuditLuggageStatusChanged
+		case after.Status != before.Status && (after.Status == models.LuggageStatusDisposed || after.Status == models.LuggageStatusLostAndFound):
+			action = models.AuditLuggageDisposed
+		}
+		events = append(events, models.AuditEvent{
+			HotelID:    l.HotelID,
+			Actor:      l.UpdatedBy,
+			Action:     action,
+			EntityType: models.AuditEntityLuggage,
+			EntityID:   l.LuggageID,
+			EntityName: after.GuestName,
+			Changes:    models.DiffJSON([]byte(l.OldData), []byte(l.NewData), "created_at", "updated_at", "note").Redact(models.LuggageRedactedFields...),
+			Note:       after.Note,
+			CreatedAt:  l.UpdatedAt,
+		})
+	}
+
+	var retrieved []models.RetrievedLog
+	DB.Find(&retrieved)
+	for _, l := range retrieved {
+		events = append(events, models.AuditEvent{
+			HotelID:    l.HotelID,
+			Actor:      l.RetrievedBy,
+			Action:     models.AuditLuggageRetrieved,
+			EntityType: models.AuditEntityLuggage,
+			EntityID:   l.LuggageID,
+			EntityName: l.GuestName,
+			Changes:    models.AuditChanges{"status": {New: models.LuggageStatusRetrieved}},
+			CreatedAt:  l.RetrievedAt,
+		})
+	}
+
+	var moved []models.MovedLog
+	DB.Find(&moved)
+	for _, l := range moved {
+		events = append(events, models.AuditEvent{
+			HotelID:    l.HotelID,
+			Actor:      l.MovedBy,
+			Action:     models.AuditLuggageMoved,
+			EntityType: models.AuditEntityLuggage,
+			EntityID:   l.LuggageID,
+			EntityName: l.GuestName,
+			Changes:    models.AuditChanges{"storeroom_id": {Old: l.FromStoreroomID, New: l.ToStoreroomID}},
+			Note:       l.Note,
+			CreatedAt:  l.MovedAt,
+		})
+	}
+
+	if len(events) == 0 {
+		return
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+	// 同一事务内写入，失败时不留下部分迁移的数据，下次启动重试
+	if err := DB.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&events, 500).Error
+	}); err != nil {
+		log.Printf("Failed to backfill audit events: %v", err)
+		return
+	}
+	log.Printf("Migrated %d log records to audit events", len(events))
+}
+
+// minSuperAdminPasswordLength 平台管理员初始密码最短长度
+const minSuperAdminPasswordLength = 12
+
+// bootstrapSuperAdmin 系统中还没有平台管理员时，用 SUPER_ADMIN_USERNAME / SUPER_ADMIN_PASSWORD 创建第一个；
+// 未设置初始密码或密码过短时拒绝创建，日志中不输出密码
+func bootstrapSuperAdmin() {
+	var count int64
+	if err := DB.Model(&models.User{}).Where("role = ?", models.RoleSuperAdmin).Count(&count).Error; err != nil {
+		log.Printf("Failed to query super admin: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	username, password := config.SuperAdminUsername, config.SuperAdminPassword
+	if password == "" {
+		log.Printf("No super admin exists; set SUPER_ADMIN_PASSWORD (and optionally SUPER_ADMIN_USERNAME) and restart to create one")
+		return
+	}
+	if len(password) < minSuperAdminPasswordLength {
+		log.Printf("SUPER_ADMIN_PASSWORD must be at least %d characters, super admin not created", minSuperAdminPasswordLength)
+		return
+	}
+
+	var existing int64
+	if err := DB.Model(&models.User{}).Where("username = ?", username).Count(&existing).Error; err != nil {
+		log.Printf("Failed to query user %s: %v", username, err)
+		return
+	}
+	if existing > 0 {
+		log.Printf("User %s already exists, super admin not created; choose another SUPER_ADMIN_USERNAME", username)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to hash super admin password: %v", err)
+		return
+	}
+	superAdmin := models.User{
+		Username:           username,
+		Password:           hashedPassword,
+		Role:               models.RoleSuperAdmin,
+		IsActive:           true,
+		MustChangePassword: true,
+	}
+	if err := DB.Create(&superAdmin).Error; err != nil {
+		log.Printf("Failed to create super admin %s: %v", username, err)
+		return
+	}
+	log.Printf("Super admin created: %s (change the password after first login and remove SUPER_ADMIN_PASSWORD)", username)
+}
+
+func seedUser(username, role string, hotelID uint) {
+	var user models.User
+	err := DB.Where("username = ?", username).First(&user).Error
 	if err == nil {
 		return
 	}
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		log.Println("Failed to query default admin user:", err)
+		log.Printf("Failed to query default user %s: %v", username, err)
 		return
 	}
 
 	hashedPassword, _ := utils.HashPassword("123456")
 	defaultUser := models.User{
-		Username: "admin",
-		Password: hashedPassword,
-		Role:     "admin",
-		HotelID:  1,
+		Username:           username,
+		Password:           hashedPassword,
+		Role:               role,
+		HotelID:            hotelID,
+		IsActive:           true,
+		MustChangePassword: true,
 	}
 	if err := DB.Create(&defaultUser).Error; err != nil {
-		log.Println("Failed to create default admin user:", err)
+		log.Printf("Failed to create default user %s: %v", username, err)
 		return
 	}
-	log.Println("Default user created: admin / 123456")
+	log.Printf("Default user created: %s / 123456", username)
 }
 
 // fixRetrievalCodeIndex 修复取件码索引：从唯一索引改为普通索引
@@ -108,3 +419,23 @@ func fixRetrievalCodeIndex() {
 	
 	// AutoMigrate 会自动创建普通索引（因为模型定义中已经是 index 而不是 uniqueIndex）
 }
+
+// ensureLuggageFulltextIndex 为 description、special_notes 建立全文索引（ngram 分词，支持中文），已存在时跳过
+func ensureLuggageFulltextIndex() {
+	var count int64
+	DB.Raw(`
+		SELECT COUNT(*)
+		FROM information_schema.STATISTICS
+		WHERE table_schema = DATABASE()
+		AND table_name = 'luggages'
+		AND index_name = 'idx_luggage_fulltext'
+	`).Scan(&count)
+	if count > 0 {
+		return
+	}
+
+	log.Printf("Creating fulltext index on luggages(description, special_notes)...")
+	if err := DB.Exec("ALTER TABLE luggages ADD FULLTEXT INDEX idx_luggage_fulltext (description, special_notes) WITH PARSER ngram").Error; err != nil {
+		log.Printf("Warning: Failed to create fulltext index: %v", err)
+	}
+}
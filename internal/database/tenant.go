@@ -0,0 +1,54 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrMissingHotelScope 查询多租户表时没有使用 HotelScope
+var ErrMissingHotelScope = errors.New("tenant table queried without hotel scope")
+
+const hotelScopeKey = "tenant:hotel_scope"
+
+// tenantTables 按酒店隔离的表，查询 / 更新 / 删除必须通过 HotelScope
+var tenantTables = map[string]bool{
+	"luggages":               true,
+	"active_retrieval_codes": true,
+}
+
+// HotelScope 限定查询在某个酒店内，所有多租户表的读写都必须使用：
+//
+//	database.DB.Scopes(database.HotelScope(hotelID)).Where(...).Find(&luggages)
+func HotelScope(hotelID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.InstanceSet(hotelScopeKey, hotelID).
+			Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "hotel_id"}, Value: hotelID})
+	}
+}
+
+// registerTenantGuard 注册回调：多租户表的查询 / 更新 / 删除未使用 HotelScope 时直接报错，
+// 避免遗漏酒店条件导致跨酒店数据泄露
+func registerTenantGuard(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenant:guard_query", tenantGuard); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenant:guard_row", tenantGuard); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:guard_update", tenantGuard); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("tenant:guard_delete", tenantGuard)
+}
+
+func tenantGuard(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || !tenantTables[db.Statement.Schema.Table] {
+		return
+	}
+	if _, ok := db.InstanceGet(hotelScopeKey); !ok {
+		_ = db.AddError(ErrMissingHotelScope)
+	}
+}
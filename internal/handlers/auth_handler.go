@@ -1,25 +1,30 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"luggage-sys2/internal/services"
+	"luggage-sys2/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
+	authService    *services.AuthService
+	sessionService *services.SessionService
 }
 
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		authService: services.NewAuthService(),
+		authService:    services.NewAuthService(),
+		sessionService: services.NewSessionService(),
 	}
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
+	Username string `json:"username" binding:"required,max=64"` // 与 users.username 列长度一致
 	Password string `json:"password" binding:"required"`
 }
 
@@ -33,7 +38,22 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.authService.Login(req.Username, req.Password)
+	user, tokens, err := h.authService.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent(), utils.GetStringFromContext(c, "request_id"))
+	if blocked, ok := err.(*services.LoginBlockedError); ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "login failed",
+			"error":   blocked.Error(),
+		})
+		return
+	}
+	if err == services.ErrAccountDisabled || err == services.ErrHotelDisabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "login failed",
+			"error":   err.Error(),
+		})
+		return
+	}
 	if err != nil || user == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": "login failed",
@@ -50,6 +70,91 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			"role":     user.Role,
 			"hotel_id": user.HotelID,
 		},
-		"token": token,
+		"token":                tokens.AccessToken,
+		"refresh_token":        tokens.RefreshToken,
+		"expires_in":           tokens.ExpiresIn,
+		"must_change_password": user.MustChangePassword,
+	})
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken 使用 refresh token 换取新的 access token 和 refresh token（旧 refresh token 失效）
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "refresh token failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	user, tokens, err := h.sessionService.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "refresh token failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "refresh token success",
+		"token":                tokens.AccessToken,
+		"refresh_token":        tokens.RefreshToken,
+		"expires_in":           tokens.ExpiresIn,
+		"must_change_password": user.MustChangePassword,
+	})
+}
+
+// Logout 吊销当前会话，access token 与 refresh token 同时失效
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID := utils.GetStringFromContext(c, "session_id")
+	if err := h.sessionService.RevokeSession(sessionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "logout failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "logout success",
+	})
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword 修改当前登录用户的密码，返回新的 token
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "change password failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	userID := utils.GetUintFromContext(c, "user_id")
+	sessionID := utils.GetStringFromContext(c, "session_id")
+	token, err := h.authService.ChangePassword(userID, sessionID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "change password failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "change password success",
+		"token":   token,
 	})
 }
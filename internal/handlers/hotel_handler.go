@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"luggage-sys2/internal/services"
+	"luggage-sys2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HotelHandler struct {
+	hotelService *services.HotelService
+}
+
+func NewHotelHandler() *HotelHandler {
+	return &HotelHandler{
+		hotelService: services.NewHotelService(),
+	}
+}
+
+// ListHotels 平台管理员查看所有酒店
+func (h *HotelHandler) ListHotels(c *gin.Context) {
+	hotels, err := h.hotelService.ListHotels()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list hotels failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list hotels success",
+		"items":   hotels,
+	})
+}
+
+// CreateHotel 平台管理员创建酒店及其首个管理员
+func (h *HotelHandler) CreateHotel(c *gin.Context) {
+	var req services.CreateHotelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create hotel failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotel, admin, err := h.hotelService.CreateHotel(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create hotel failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "create hotel success",
+		"item":    hotel,
+		"admin":   admin,
+	})
+}
+
+// UpdateHotel 平台管理员修改酒店设置或启用/停用酒店
+func (h *HotelHandler) UpdateHotel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update hotel failed",
+			"error":   "invalid hotel id",
+		})
+		return
+	}
+
+	var req services.UpdateHotelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update hotel failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotel, err := h.hotelService.UpdateHotel(uint(id), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update hotel failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "update hotel success",
+		"item":    hotel,
+	})
+}
+
+// GetCurrentHotel 当前登录用户所属酒店及设置
+func (h *HotelHandler) GetCurrentHotel(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	hotel, err := h.hotelService.GetHotel(hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "get hotel failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "get hotel success",
+		"item":    hotel,
+	})
+}
+
+// UpdateCurrentHotelSettings 酒店管理员修改本酒店设置
+func (h *HotelHandler) UpdateCurrentHotelSettings(c *gin.Context) {
+	var req services.HotelSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update hotel settings failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	hotel, err := h.hotelService.UpdateSettings(hotelID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update hotel settings failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "update hotel settings success",
+		"item":    hotel,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"luggage-sys2/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseListQuery 解析列表接口通用的分页、过滤和排序参数：
+// cursor, limit, from, to（RFC 3339）, guest_name, staff, luggage_id, sort, order
+func parseListQuery(c *gin.Context) (services.ListQuery, error) {
+	q := services.ListQuery{
+		Cursor:    c.Query("cursor"),
+		GuestName: c.Query("guest_name"),
+		Staff:     c.Query("staff"),
+		Sort:      c.Query("sort"),
+		Order:     c.Query("order"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return q, errors.New("invalid limit")
+		}
+		q.Limit = limit
+	}
+	if v := c.Query("luggage_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return q, errors.New("invalid luggage_id")
+		}
+		q.LuggageID = uint(id)
+	}
+	for key, dst := range map[string]**time.Time{
+		"from": &q.From,
+		"to":   &q.To,
+	} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, errors.New("invalid " + key + ", expected RFC 3339 time")
+			}
+			*dst = &t
+		}
+	}
+	return q, nil
+}
+
+// listPageResponse 分页列表的统一响应：items、total（满足过滤条件的总数）、next_cursor（为空表示没有下一页）
+func listPageResponse(message string, items interface{}, total int64, nextCursor string) gin.H {
+	return gin.H{
+		"message":     message,
+		"items":       items,
+		"total":       total,
+		"next_cursor": nextCursor,
+	}
+}
@@ -29,7 +29,7 @@ func (h *LogHandler) GetStoredLogs(c *gin.Context) {
 		return
 	}
 
-	logs, err := h.logService.GetStoredLogs(hotelID)
+	q, err := parseListQuery(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "list logs failed",
@@ -38,10 +38,16 @@ func (h *LogHandler) GetStoredLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "list logs success",
-		"items":   logs,
-	})
+	page, err := h.logService.GetStoredLogs(hotelID, q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, listPageResponse("list logs success", page.Items, page.Total, page.NextCursor))
 }
 
 func (h *LogHandler) GetUpdatedLogs(c *gin.Context) {
@@ -54,7 +60,16 @@ func (h *LogHandler) GetUpdatedLogs(c *gin.Context) {
 		return
 	}
 
-	logs, err := h.logService.GetUpdatedLogs(hotelID)
+	q, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	page, err := h.logService.GetUpdatedLogs(hotelID, q)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "list logs failed",
@@ -63,10 +78,7 @@ func (h *LogHandler) GetUpdatedLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "list logs success",
-		"items":   logs,
-	})
+	c.JSON(http.StatusOK, listPageResponse("list logs success", page.Items, page.Total, page.NextCursor))
 }
 
 func (h *LogHandler) GetRetrievedLogs(c *gin.Context) {
@@ -79,7 +91,47 @@ func (h *LogHandler) GetRetrievedLogs(c *gin.Context) {
 		return
 	}
 
-	logs, err := h.logService.GetRetrievedLogs(hotelID)
+	q, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	page, err := h.logService.GetRetrievedLogs(hotelID, q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, listPageResponse("list logs success", page.Items, page.Total, page.NextCursor))
+}
+
+func (h *LogHandler) GetMovedLogs(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if hotelID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   "hotel_id is missing",
+		})
+		return
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	page, err := h.logService.GetMovedLogs(hotelID, q)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "list logs failed",
@@ -88,8 +140,5 @@ func (h *LogHandler) GetRetrievedLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "list logs success",
-		"items":   logs,
-	})
+	c.JSON(http.StatusOK, listPageResponse("list logs success", page.Items, page.Total, page.NextCursor))
 }
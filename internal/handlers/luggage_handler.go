@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"luggage-sys2/internal/models"
 	"luggage-sys2/internal/services"
 	"luggage-sys2/internal/utils"
 
@@ -36,7 +40,7 @@ func (h *LuggageHandler) CreateLuggage(c *gin.Context) {
 		req.StaffName = username
 	}
 
-	luggage, code, err := h.luggageService.CreateLuggage(req, hotelID)
+	luggage, code, pin, err := h.luggageService.CreateLuggage(req, hotelID, requestActor(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "create luggage failed",
@@ -54,25 +58,41 @@ func (h *LuggageHandler) CreateLuggage(c *gin.Context) {
 			items = append(items, gin.H{
 				"luggage_id":  l.ID,
 				"storeroom_id": l.StoreroomID,
+				"slot_id":      l.SlotID,
+				"slot_code":    l.SlotCode,
 				"photo_url":   l.PhotoURL,
 				"photo_urls":  l.PhotoURLs,
 			})
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "create luggage success",
+		resp := gin.H{
+			"message":        "create luggage success",
 			"retrieval_code": code,
-			"items":         items,
-		})
+			"qrcode_url":     "/qr/" + code,
+			"items":          items,
+		}
+		if pin != "" {
+			resp["verification_pin"] = pin
+		}
+		c.JSON(http.StatusOK, resp)
 	} else {
 		// 单件模式：保持原有响应格式
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "create luggage success",
-			"luggage_id":    luggage.ID,
+		resp := gin.H{
+			"message":        "create luggage success",
+			"luggage_id":     luggage.ID,
 			"retrieval_code": code,
-			"qrcode_url":    "/qr/" + code,
-			"photo_url":     luggage.PhotoURL,
-			"photo_urls":    luggage.PhotoURLs,
-		})
+			"qrcode_url":     "/qr/" + code,
+			"photo_url":      luggage.PhotoURL,
+			"photo_urls":     luggage.PhotoURLs,
+		}
+		if luggage.SlotID != nil {
+			resp["slot_id"] = luggage.SlotID
+			resp["slot_code"] = luggage.SlotCode
+		}
+		// 酒店启用 PIN 验证时返回一次性取件 PIN，交给客人取件时出示
+		if pin != "" {
+			resp["verification_pin"] = pin
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
 
@@ -103,7 +123,12 @@ func (h *LuggageHandler) GetLuggageByCode(c *gin.Context) {
 			"id":            luggage.ID,
 			"guest_name":    luggage.GuestName,
 			"contact_phone": luggage.ContactPhone,
+			"room_number":   luggage.RoomNumber,
+			"reservation_id": luggage.ReservationID,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
 			"storeroom_id":  luggage.StoreroomID,
+			"slot_id":       luggage.SlotID,
+			"slot_code":     luggage.SlotCode,
 			"retrieval_code": luggage.RetrievalCode,
 			"status":        luggage.Status,
 			"photo_url":     luggage.PhotoURL,
@@ -127,44 +152,80 @@ func (h *LuggageHandler) CheckoutLuggage(c *gin.Context) {
 		return
 	}
 
+	// 客户端重试时携带相同的 Idempotency-Key，返回首次取件结果
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if len(idempotencyKey) > 128 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "checkout failed",
+			"error":   "Idempotency-Key is too long",
+		})
+		return
+	}
+
+	// 请求体可选：酒店启用二次验证时携带 verification
+	var req services.CheckoutLuggageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "checkout failed",
+				"error":   "invalid request",
+			})
+			return
+		}
+	}
+
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
-	username := utils.GetStringFromContext(c, "username")
 
-	luggageIDs, err := h.luggageService.CheckoutLuggage(code, username, hotelID)
+	luggageIDs, remaining, err := h.luggageService.CheckoutLuggage(code, req, hotelID, requestActor(c), idempotencyKey)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		var verifyErr *services.VerificationFailedError
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrLuggageAlreadyRetrieved) {
+			status = http.StatusConflict
+		} else if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			status = http.StatusUnprocessableEntity
+		} else if errors.Is(err, services.ErrRetrievalCodeLocked) {
+			status = http.StatusLocked
+		} else if errors.Is(err, services.ErrVerificationRequired) || errors.As(err, &verifyErr) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
 			"message": "checkout failed",
 			"error":   err.Error(),
 		})
 		return
 	}
 
-	// 根据文档，多件模式返回 retrieved_count 和 luggage_ids，单件模式返回 luggage_id
-	if len(luggageIDs) > 1 {
-		c.JSON(http.StatusOK, gin.H{
-			"message":        "checkout success",
-			"retrieval_code": code,
-			"retrieved_count": len(luggageIDs),
-			"luggage_ids":    luggageIDs,
-			"luggage_id":     nil,
-		})
-	} else if len(luggageIDs) == 1 {
-		c.JSON(http.StatusOK, gin.H{
-			"message":        "checkout success",
-			"retrieval_code": code,
-			"retrieved_count": 1,
-			"luggage_ids":    luggageIDs,
-			"luggage_id":     luggageIDs[0],
-		})
-	} else {
-		c.JSON(http.StatusOK, gin.H{
-			"message":        "checkout success",
-			"retrieval_code": code,
-			"retrieved_count": 0,
-			"luggage_ids":    []uint{},
-			"luggage_id":     nil,
+	// 部分取件时返回取件码下剩余的在存行李，取件码继续有效
+	remainingItems := make([]gin.H, 0, len(remaining))
+	for i, l := range remaining {
+		remainingItems = append(remainingItems, gin.H{
+			"item_index":   i + 1,
+			"luggage_id":   l.ID,
+			"description":  l.Description,
+			"quantity":     l.Quantity,
+			"storeroom_id": l.StoreroomID,
+			"slot_id":      l.SlotID,
+			"slot_code":    l.SlotCode,
 		})
 	}
+
+	// 根据文档，多件模式返回 retrieved_count 和 luggage_ids，单件模式返回 luggage_id
+	resp := gin.H{
+		"message":         "checkout success",
+		"retrieval_code":  code,
+		"retrieved_count": len(luggageIDs),
+		"luggage_ids":     luggageIDs,
+		"luggage_id":      nil,
+		"remaining_count": len(remaining),
+		"remaining_items": remainingItems,
+	}
+	if len(luggageIDs) == 1 {
+		resp["luggage_id"] = luggageIDs[0]
+	} else if len(luggageIDs) == 0 {
+		resp["luggage_ids"] = []uint{}
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *LuggageHandler) GetGuestList(c *gin.Context) {
@@ -201,8 +262,17 @@ func (h *LuggageHandler) GetLuggageByGuestName(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseLuggageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
-	luggages, err := h.luggageService.GetLuggageByGuestName(guestName, hotelID)
+	luggages, err := h.luggageService.GetLuggageByGuestName(guestName, hotelID, filter)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "list luggage failed",
@@ -216,8 +286,14 @@ func (h *LuggageHandler) GetLuggageByGuestName(c *gin.Context) {
 		items = append(items, gin.H{
 			"id":            luggage.ID,
 			"guest_name":    luggage.GuestName,
+			"room_number":   luggage.RoomNumber,
+			"reservation_id": luggage.ReservationID,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
 			"retrieval_code": luggage.RetrievalCode,
 			"status":        luggage.Status,
+			"storeroom_id":  luggage.StoreroomID,
+			"slot_id":       luggage.SlotID,
+			"slot_code":     luggage.SlotCode,
 			"photo_url":     luggage.PhotoURL,
 			"photo_urls":    luggage.PhotoURLs,
 		})
@@ -250,9 +326,8 @@ func (h *LuggageHandler) UpdateLuggage(c *gin.Context) {
 	}
 
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
-	username := utils.GetStringFromContext(c, "username")
 
-	if err := h.luggageService.UpdateLuggage(uint(id), req, hotelID, username); err != nil {
+	if err := h.luggageService.UpdateLuggage(uint(id), req, hotelID, requestActor(c)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "update luggage failed",
 			"error":   err.Error(),
@@ -264,3 +339,381 @@ func (h *LuggageHandler) UpdateLuggage(c *gin.Context) {
 		"message": "update luggage success",
 	})
 }
+
+// UnlockRetrievalCode 解除因二次验证失败次数过多而锁定的取件码
+func (h *LuggageHandler) UnlockRetrievalCode(c *gin.Context) {
+	code := c.Param("code")
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	if err := h.luggageService.UnlockRetrievalCode(code, hotelID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "unlock retrieval code failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "unlock retrieval code success",
+	})
+}
+
+// ListOverdueLuggage 逾期 / 无人认领的行李：GET /api/luggage/overdue?status=overdue|abandoned
+func (h *LuggageHandler) ListOverdueLuggage(c *gin.Context) {
+	filter, err := parseLuggageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list overdue luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	luggages, err := h.luggageService.ListOverdueLuggage(hotelID, c.Query("status"), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list overdue luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(luggages))
+	for _, luggage := range luggages {
+		items = append(items, gin.H{
+			"id":                 luggage.ID,
+			"guest_name":         luggage.GuestName,
+			"contact_phone":      luggage.ContactPhone,
+			"room_number":        luggage.RoomNumber,
+			"reservation_id":     luggage.ReservationID,
+			"storeroom_id":       luggage.StoreroomID,
+			"slot_id":            luggage.SlotID,
+			"slot_code":          luggage.SlotCode,
+			"retrieval_code":     luggage.RetrievalCode,
+			"status":             luggage.Status,
+			"stored_at":          luggage.StoredAt,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
+			"overdue_at":         luggage.OverdueAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list overdue luggage success",
+		"items":   items,
+	})
+}
+
+// DisposeLuggage 处置逾期行李
+func (h *LuggageHandler) DisposeLuggage(c *gin.Context) {
+	h.disposeLuggage(c, models.LuggageStatusDisposed)
+}
+
+// TransferToLostAndFound 逾期行李移交失物招领
+func (h *LuggageHandler) TransferToLostAndFound(c *gin.Context) {
+	h.disposeLuggage(c, models.LuggageStatusLostAndFound)
+}
+
+func (h *LuggageHandler) disposeLuggage(c *gin.Context, disposition string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "dispose luggage failed",
+			"error":   "invalid luggage id",
+		})
+		return
+	}
+
+	var req services.DisposeLuggageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "dispose luggage failed",
+				"error":   "invalid request",
+			})
+			return
+		}
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	luggage, err := h.luggageService.DisposeLuggage(uint(id), disposition, req, hotelID, requestActor(c))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrLuggageNotOverdue) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"message": "dispose luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "dispose luggage success",
+		"luggage_id":  luggage.ID,
+		"status":      luggage.Status,
+		"disposed_at": luggage.DisposedAt,
+		"disposed_by": luggage.DisposedBy,
+	})
+}
+
+// MoveLuggage 把一件在存行李移到本酒店另一个寄存室：POST /api/luggage/{id}/move
+func (h *LuggageHandler) MoveLuggage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "move luggage failed",
+			"error":   "invalid luggage id",
+		})
+		return
+	}
+
+	var req services.MoveLuggageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "move luggage failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	luggage, err := h.luggageService.MoveLuggage(uint(id), req, hotelID, requestActor(c))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrLuggageAlreadyRetrieved) || errors.Is(err, services.ErrStoreroomFull) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"message": "move luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "move luggage success",
+		"luggage_id":   luggage.ID,
+		"storeroom_id": luggage.StoreroomID,
+	})
+}
+
+// ListDueLuggage 某天预计取件的行李（默认酒店时区的今天）：GET /api/luggage/due?date=2026-01-23
+func (h *LuggageHandler) ListDueLuggage(c *gin.Context) {
+	filter, err := parseLuggageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list due luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	luggages, date, err := h.luggageService.ListDueLuggage(hotelID, c.Query("date"), filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list due luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(luggages))
+	for _, luggage := range luggages {
+		items = append(items, gin.H{
+			"id":                 luggage.ID,
+			"guest_name":         luggage.GuestName,
+			"room_number":        luggage.RoomNumber,
+			"reservation_id":     luggage.ReservationID,
+			"storeroom_id":       luggage.StoreroomID,
+			"slot_id":            luggage.SlotID,
+			"slot_code":          luggage.SlotCode,
+			"retrieval_code":     luggage.RetrievalCode,
+			"description":        luggage.Description,
+			"quantity":           luggage.Quantity,
+			"status":             luggage.Status,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list due luggage success",
+		"date":    date,
+		"items":   items,
+	})
+}
+
+// SearchLuggage 按客人姓名、电话后几位、房号、描述关键词、状态、寄存室和时间范围搜索行李：GET /api/luggage/search
+func (h *LuggageHandler) SearchLuggage(c *gin.Context) {
+	search, err := parseLuggageSearch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "search luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+	q, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "search luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	page, err := h.luggageService.SearchLuggage(hotelID, search, q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "search luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	items := make([]gin.H, 0, len(page.Items))
+	for _, luggage := range page.Items {
+		items = append(items, gin.H{
+			"id":                 luggage.ID,
+			"guest_name":         luggage.GuestName,
+			"contact_phone":      luggage.ContactPhone,
+			"room_number":        luggage.RoomNumber,
+			"reservation_id":     luggage.ReservationID,
+			"storeroom_id":       luggage.StoreroomID,
+			"slot_id":            luggage.SlotID,
+			"slot_code":          luggage.SlotCode,
+			"retrieval_code":     luggage.RetrievalCode,
+			"description":        luggage.Description,
+			"special_notes":      luggage.SpecialNotes,
+			"quantity":           luggage.Quantity,
+			"size_class":         luggage.SizeClass,
+			"status":             luggage.Status,
+			"staff_name":         luggage.StaffName,
+			"stored_at":          luggage.StoredAt,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
+			"retrieved_at":       luggage.RetrievedAt,
+			"photo_url":          luggage.PhotoURL,
+		})
+	}
+
+	c.JSON(http.StatusOK, listPageResponse("search luggage success", items, page.Total, page.NextCursor))
+}
+
+// parseLuggageSearch 解析行李搜索条件：parseLuggageFilter 的通用过滤参数，以及 phone（后几位）、keywords、
+// status（逗号分隔）、storeroom_id、retrieved_from、retrieved_to
+func parseLuggageSearch(c *gin.Context) (services.LuggageSearch, error) {
+	filter, err := parseLuggageFilter(c)
+	if err != nil {
+		return services.LuggageSearch{}, err
+	}
+	search := services.LuggageSearch{
+		LuggageFilter: filter,
+		PhoneSuffix:   strings.TrimSpace(c.Query("phone")),
+		Keywords:      c.Query("keywords"),
+	}
+	if v := c.Query("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				search.Statuses = append(search.Statuses, status)
+			}
+		}
+	}
+	if v := c.Query("storeroom_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return search, errors.New("invalid storeroom_id")
+		}
+		search.StoreroomID = uint(id)
+	}
+	for key, dst := range map[string]**time.Time{
+		"retrieved_from": &search.RetrievedFrom,
+		"retrieved_to":   &search.RetrievedTo,
+	} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return search, errors.New("invalid " + key + ", expected RFC 3339 time")
+			}
+			*dst = &t
+		}
+	}
+	return search, nil
+}
+
+// parseLuggageFilter 读取行李列表通用过滤参数：room_number、reservation_id、expected_from、expected_to（RFC 3339）
+func parseLuggageFilter(c *gin.Context) (services.LuggageFilter, error) {
+	filter := services.LuggageFilter{
+		RoomNumber:    c.Query("room_number"),
+		ReservationID: c.Query("reservation_id"),
+	}
+	for key, dst := range map[string]**time.Time{
+		"expected_from": &filter.ExpectedFrom,
+		"expected_to":   &filter.ExpectedTo,
+	} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, errors.New("invalid " + key + ", expected RFC 3339 time")
+			}
+			*dst = &t
+		}
+	}
+	return filter, nil
+}
+
+// AppendLuggageItems 向已有取件码追加行李：POST /api/luggage/codes/:code/items
+func (h *LuggageHandler) AppendLuggageItems(c *gin.Context) {
+	var req services.AppendLuggageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "append luggage failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	code := c.Param("code")
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	created, err := h.luggageService.AppendLuggageItems(code, req, hotelID, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "append luggage failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 返回取件码下全部在存行李，序号与行李标签一致
+	allLuggages, _ := h.luggageService.GetLuggageByCode(code, hotelID)
+	items := make([]gin.H, 0, len(allLuggages))
+	for i, l := range allLuggages {
+		items = append(items, gin.H{
+			"item_index":   i + 1,
+			"luggage_id":   l.ID,
+			"storeroom_id": l.StoreroomID,
+			"slot_id":      l.SlotID,
+			"slot_code":    l.SlotCode,
+			"description":  l.Description,
+			"quantity":     l.Quantity,
+			"photo_url":    l.PhotoURL,
+			"photo_urls":   l.PhotoURLs,
+		})
+	}
+	addedIDs := make([]uint, 0, len(created))
+	for _, l := range created {
+		addedIDs = append(addedIDs, l.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "append luggage success",
+		"retrieval_code": created[0].RetrievalCode,
+		"added_ids":      addedIDs,
+		"items":          items,
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"luggage-sys2/internal/services"
+	"luggage-sys2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationHandler() *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: services.NewNotificationService(),
+	}
+}
+
+// ListTemplates 本酒店各事件 + 渠道当前生效的通知模板
+func (h *NotificationHandler) ListTemplates(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	items, err := h.notificationService.ListTemplates(hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list notification templates failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list notification templates success",
+		"items":   items,
+	})
+}
+
+// SaveTemplate 自定义某个事件 + 渠道的通知模板
+func (h *NotificationHandler) SaveTemplate(c *gin.Context) {
+	var req services.SaveNotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "save notification template failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	tpl, err := h.notificationService.SaveTemplate(hotelID, c.Param("event"), c.Param("channel"), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "save notification template failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "save notification template success",
+		"item":    tpl,
+	})
+}
+
+// ResetTemplate 恢复某个事件 + 渠道的默认通知模板
+func (h *NotificationHandler) ResetTemplate(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if err := h.notificationService.ResetTemplate(hotelID, c.Param("event"), c.Param("channel")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "reset notification template failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "reset notification template success",
+	})
+}
+
+// ListLogs 本酒店通知发送记录，可按取件码过滤：GET /api/notification_logs?code=123456&limit=100
+func (h *NotificationHandler) ListLogs(c *gin.Context) {
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		v, err := strconv.Atoi(limitStr)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "list notification logs failed",
+				"error":   "invalid limit",
+			})
+			return
+		}
+		limit = v
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	logs, err := h.notificationService.ListLogs(hotelID, c.Query("code"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list notification logs failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list notification logs success",
+		"items":   logs,
+	})
+}
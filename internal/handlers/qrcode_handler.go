@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"luggage-sys2/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QRCodeHandler struct {
+	qrcodeService *services.QRCodeService
+}
+
+func NewQRCodeHandler() *QRCodeHandler {
+	return &QRCodeHandler{
+		qrcodeService: services.NewQRCodeService(),
+	}
+}
+
+// GetQRCode handles:
+//
+//	GET /qr/:code?format=png|svg&size=256&level=L|M|Q|H
+//
+// 返回编码了取件码的二维码图片，供前台打印或展示给客人扫码
+func (h *QRCodeHandler) GetQRCode(c *gin.Context) {
+	code := c.Param("code")
+
+	size := 0
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		v, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "generate qrcode failed",
+				"error":   services.ErrInvalidQRCodeSize.Error(),
+			})
+			return
+		}
+		size = v
+	}
+
+	data, contentType, err := h.qrcodeService.GenerateQRCode(code, services.QRCodeOptions{
+		Format: c.Query("format"),
+		Size:   size,
+		Level:  c.Query("level"),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "generate qrcode failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 二维码内容只由取件码决定，可以让浏览器缓存
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, data)
+}
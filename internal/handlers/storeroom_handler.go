@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -12,11 +13,13 @@ import (
 
 type StoreroomHandler struct {
 	storeroomService *services.StoreroomService
+	luggageService   *services.LuggageService
 }
 
 func NewStoreroomHandler() *StoreroomHandler {
 	return &StoreroomHandler{
 		storeroomService: services.NewStoreroomService(),
+		luggageService:   services.NewLuggageService(),
 	}
 }
 
@@ -56,7 +59,7 @@ func (h *StoreroomHandler) CreateStoreroom(c *gin.Context) {
 	}
 
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
-	storeroom, err := h.storeroomService.CreateStoreroom(req, hotelID)
+	storeroom, err := h.storeroomService.CreateStoreroom(req, hotelID, requestActor(c))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": "create storeroom failed",
@@ -71,12 +74,13 @@ func (h *StoreroomHandler) CreateStoreroom(c *gin.Context) {
 	})
 }
 
+// UpdateStoreroom 修改寄存室（PATCH 语义，未传的字段不变），PUT 与 PATCH 共用
 func (h *StoreroomHandler) UpdateStoreroom(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "update storeroom status failed",
+			"message": "update storeroom failed",
 			"error":   "invalid storeroom id",
 		})
 		return
@@ -85,23 +89,114 @@ func (h *StoreroomHandler) UpdateStoreroom(c *gin.Context) {
 	var req services.UpdateStoreroomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "update storeroom status failed",
+			"message": "update storeroom failed",
 			"error":   "invalid request",
 		})
 		return
 	}
 
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
-	if err := h.storeroomService.UpdateStoreroom(uint(id), req, hotelID); err != nil {
+	storeroom, err := h.storeroomService.UpdateStoreroom(uint(id), req, hotelID, requestActor(c))
+	if err != nil {
+		c.JSON(storeroomErrorStatus(err), gin.H{
+			"message": "update storeroom failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "update storeroom success",
+		"item":    storeroom,
+	})
+}
+
+// DeleteStoreroom 软删除寄存室，寄存室内仍有行李时需在请求体中指定 transfer_to_storeroom_id
+func (h *StoreroomHandler) DeleteStoreroom(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "delete storeroom failed",
+			"error":   "invalid storeroom id",
+		})
+		return
+	}
+
+	var req services.DeleteStoreroomRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "delete storeroom failed",
+				"error":   "invalid request",
+			})
+			return
+		}
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if err := h.storeroomService.DeleteStoreroom(uint(id), req, hotelID, requestActor(c)); err != nil {
+		c.JSON(storeroomErrorStatus(err), gin.H{
+			"message": "delete storeroom failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "delete storeroom success",
+	})
+}
+
+// storeroomErrorStatus 寄存室仍有行李、目标寄存室容量不足时返回 409，其余 400
+func storeroomErrorStatus(err error) int {
+	if errors.Is(err, services.ErrStoreroomNotEmpty) || errors.Is(err, services.ErrStoreroomFull) {
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// TransferLuggage 把寄存室内全部在存行李移到另一个寄存室：POST /api/luggage/storerooms/{id}/transfer
+func (h *StoreroomHandler) TransferLuggage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "transfer luggage failed",
+			"error":   "invalid storeroom id",
+		})
+		return
+	}
+
+	var req services.MoveLuggageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "update storeroom status failed",
+			"message": "transfer luggage failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	moved, err := h.luggageService.MoveStoreroomLuggage(uint(id), req, hotelID, requestActor(c))
+	if err != nil {
+		c.JSON(storeroomErrorStatus(err), gin.H{
+			"message": "transfer luggage failed",
 			"error":   err.Error(),
 		})
 		return
 	}
 
+	movedIDs := make([]uint, 0, len(moved))
+	for _, luggage := range moved {
+		movedIDs = append(movedIDs, luggage.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "update storeroom status success",
+		"message":           "transfer luggage success",
+		"from_storeroom_id": uint(id),
+		"to_storeroom_id":   req.ToStoreroomID,
+		"moved_count":       len(movedIDs),
+		"moved_ids":         movedIDs,
 	})
 }
 
@@ -115,10 +210,36 @@ func (h *StoreroomHandler) GetStoreroomOrders(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseLuggageFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
 	hotelID := utils.GetUintFromContext(c, "hotel_id")
 	status := c.Query("status")
 
-	luggages, err := h.storeroomService.GetStoreroomOrders(uint(id), hotelID, status)
+	page, err := h.storeroomService.GetStoreroomOrders(uint(id), hotelID, status, filter, q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	luggages := page.Items
+
+	// 从其他寄存室移入的行李附带最近一次移库信息
+	moves, err := h.storeroomService.LastMoves(hotelID, luggages)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"message": err.Error(),
@@ -128,16 +249,131 @@ func (h *StoreroomHandler) GetStoreroomOrders(c *gin.Context) {
 
 	items := make([]gin.H, 0, len(luggages))
 	for _, luggage := range luggages {
-		items = append(items, gin.H{
+		item := gin.H{
 			"id":            luggage.ID,
 			"guest_name":    luggage.GuestName,
+			"room_number":   luggage.RoomNumber,
+			"reservation_id": luggage.ReservationID,
+			"expected_pickup_at": luggage.ExpectedPickupAt,
 			"retrieval_code": luggage.RetrievalCode,
 			"status":        luggage.Status,
+			"slot_id":       luggage.SlotID,
+			"slot_code":     luggage.SlotCode,
+		}
+		if move, ok := moves[luggage.ID]; ok {
+			item["moved_from_storeroom_id"] = move.FromStoreroomID
+			item["moved_at"] = move.MovedAt
+			item["moved_by"] = move.MovedBy
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, listPageResponse("list luggage success", items, page.Total, page.NextCursor))
+}
+
+// ListSlots 寄存室内的格位及占用：GET /api/luggage/storerooms/{id}/slots
+func (h *StoreroomHandler) ListSlots(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list storage slots failed",
+			"error":   "invalid storeroom id",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	slots, err := h.storeroomService.ListSlots(uint(id), hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list storage slots failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list storage slots success",
+		"items":   slots,
+	})
+}
+
+// CreateSlots 在寄存室下批量创建格位：POST /api/luggage/storerooms/{id}/slots
+func (h *StoreroomHandler) CreateSlots(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create storage slots failed",
+			"error":   "invalid storeroom id",
+		})
+		return
+	}
+
+	var req services.CreateStorageSlotsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create storage slots failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	slots, err := h.storeroomService.CreateSlots(uint(id), req, hotelID, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create storage slots failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "create storage slots success",
+		"items":   slots,
+	})
+}
+
+// UpdateSlot 修改格位编号、容量或停用：PATCH /api/luggage/storerooms/{id}/slots/{slot_id}
+func (h *StoreroomHandler) UpdateSlot(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update storage slot failed",
+			"error":   "invalid storeroom id",
+		})
+		return
+	}
+	slotID, err := strconv.ParseUint(c.Param("slot_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update storage slot failed",
+			"error":   "invalid slot id",
+		})
+		return
+	}
+
+	var req services.UpdateStorageSlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update storage slot failed",
+			"error":   "invalid request",
 		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	slot, err := h.storeroomService.UpdateSlot(uint(id), uint(slotID), req, hotelID, requestActor(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update storage slot failed",
+			"error":   err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "list luggage success",
-		"items":   items,
+		"message": "update storage slot success",
+		"item":    slot,
 	})
 }
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+
+	"luggage-sys2/internal/services"
+	"luggage-sys2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TicketHandler struct {
+	ticketService *services.TicketService
+}
+
+func NewTicketHandler() *TicketHandler {
+	return &TicketHandler{
+		ticketService: services.NewTicketService(),
+	}
+}
+
+// GetClaimTicket handles:
+//
+//	GET /api/luggage/:id/ticket
+//
+// Path 参数 id 实际传取件码（与取件接口一致），返回可打印的寄存凭条与行李标签 PDF
+func (h *TicketHandler) GetClaimTicket(c *gin.Context) {
+	code := c.Param("id")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "generate ticket failed",
+			"error":   "code is empty",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	pdf, code, err := h.ticketService.GenerateClaimTicket(code, hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "generate ticket failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 文件名使用服务返回的规范化取件码，并按 MIME 参数规则转义，避免原始路径参数注入响应头
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "ticket-" + code + ".pdf"}))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
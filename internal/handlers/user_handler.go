@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"luggage-sys2/internal/services"
+	"luggage-sys2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	userService       *services.UserService
+	loginGuardService *services.LoginGuardService
+}
+
+func NewUserHandler() *UserHandler {
+	return &UserHandler{
+		userService:       services.NewUserService(),
+		loginGuardService: services.NewLoginGuardService(),
+	}
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if hotelID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list users failed",
+			"error":   "hotel_id is missing",
+		})
+		return
+	}
+
+	users, err := h.userService.ListUsers(hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list users failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list users success",
+		"items":   users,
+	})
+}
+
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req services.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create user failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	user, err := h.userService.CreateUser(req, hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "create user failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "create user success",
+		"item":    user,
+	})
+}
+
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user role failed",
+			"error":   "invalid user id",
+		})
+		return
+	}
+
+	var req services.UpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user role failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	operatorID := utils.GetUintFromContext(c, "user_id")
+	if err := h.userService.UpdateUserRole(uint(id), req, hotelID, operatorID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user role failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "update user role success",
+	})
+}
+
+func (h *UserHandler) UpdateUserStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user status failed",
+			"error":   "invalid user id",
+		})
+		return
+	}
+
+	var req services.UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user status failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	operatorID := utils.GetUintFromContext(c, "user_id")
+	if err := h.userService.UpdateUserStatus(uint(id), req, hotelID, operatorID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "update user status failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "update user status success",
+	})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "reset password failed",
+			"error":   "invalid user id",
+		})
+		return
+	}
+
+	var req services.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "reset password failed",
+			"error":   "invalid request",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if err := h.userService.ResetPassword(uint(id), req, hotelID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "reset password failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "reset password success",
+	})
+}
+
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "revoke sessions failed",
+			"error":   "invalid user id",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	count, err := h.userService.RevokeUserSessions(uint(id), hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "revoke sessions failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "revoke sessions success",
+		"revoked_count": count,
+	})
+}
+
+// ListLockouts 查看本酒店账号的登录失败计数与锁定状态
+func (h *UserHandler) ListLockouts(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	items, err := h.loginGuardService.ListLockouts(hotelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list lockouts failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list lockouts success",
+		"items":   items,
+	})
+}
+
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "unlock user failed",
+			"error":   "invalid user id",
+		})
+		return
+	}
+
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+	if err := h.loginGuardService.Unlock(uint(id), hotelID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "unlock user failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "unlock user success",
+	})
+}
+
+// ListFailedLogins 查看本酒店账号的失败登录记录，支持 username / client_ip 过滤
+func (h *UserHandler) ListFailedLogins(c *gin.Context) {
+	hotelID := utils.GetUintFromContext(c, "hotel_id")
+
+	limit := 200
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if v, err := strconv.Atoi(limitStr); err == nil && v > 0 && v <= 1000 {
+			limit = v
+		}
+	}
+
+	items, err := h.loginGuardService.ListFailedAttempts(hotelID, c.Query("username"), c.Query("client_ip"), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "list login attempts failed",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "list login attempts success",
+		"items":   items,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	"luggage-sys2/internal/services"
 	"luggage-sys2/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +12,7 @@ import (
 
 // AuthMiddleware JWT认证中间件
 func AuthMiddleware() gin.HandlerFunc {
+	sessionService := services.NewSessionService()
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -42,10 +44,58 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 服务端吊销检查：登出、被管理员强制下线、账号停用后 token 立即失效
+		if !sessionService.IsSessionActive(claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": "invalid token",
+				"error":   "session has been revoked or expired",
+			})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文
 		c.Set("user_id", int(claims.UserID))
 		c.Set("username", claims.Username)
 		c.Set("hotel_id", int(claims.HotelID))
+		c.Set("role", claims.Role)
+		c.Set("must_change_password", claims.MustChangePassword)
+		c.Set("session_id", claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// RequireRole 角色校验中间件，必须挂在 AuthMiddleware 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := utils.GetStringFromContext(c, "role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "permission denied",
+			"error":   "role '" + role + "' is not allowed to access this resource",
+		})
+		c.Abort()
+	}
+}
+
+// PasswordChangedMiddleware 强制改密校验：首次登录或被重置密码的账号在修改密码前不能访问其他接口
+func PasswordChangedMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("must_change_password") {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "password change required",
+				"error":   "please change your password before continuing",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"regexp"
+
+	"luggage-sys2/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求 ID 头，客户端可自带，服务端在响应中回传
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID 客户端传入的请求 ID 只接受常见字符，最长 64 个
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestIDMiddleware 为每个请求分配请求 ID（沿用客户端传入的合法值），写入上下文 request_id 和响应头，
+// 审计事件据此关联同一请求产生的变更
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID, _ = utils.GenerateRandomToken(16)
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
@@ -0,0 +1,311 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计动作，格式为 <实体类型>.<动作>
+const (
+	AuditLuggageStored        = "luggage.stored"
+	AuditLuggageUpdated       = "luggage.updated"
+	AuditLuggageStatusChanged = "luggage.status_changed" // 后台任务标记逾期 / 无人认领
+	AuditLuggageRetrieved     = "luggage.retrieved"
+	AuditLuggageMoved         = "luggage.moved"
+	AuditLuggageDisposed      = "luggage.disposed" // 处置或移交失物招领
+
+	AuditStoreroomCreated = "storeroom.created"
+	AuditStoreroomUpdated = "storeroom.updated"
+	AuditStoreroomDeleted = "storeroom.deleted"
+	AuditSlotCreated      = "slot.created"
+	AuditSlotUpdated      = "slot.updated"
+
+	AuditLoginSucceeded = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+)
+
+// 审计实体类型
+const (
+	AuditEntityLuggage   = "luggage"
+	AuditEntityStoreroom = "storeroom"
+	AuditEntitySlot      = "storage_slot"
+	AuditEntityUser      = "user"
+)
+
+var ErrAuditAppendOnly = errors.New("audit events are append-only")
+
+// AuditEvent 审计事件：所有业务变更和登录统一写入此表，与变更在同一事务中写入，只追加不修改
+type AuditEvent struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	HotelID    uint         `gorm:"not null;default:0;index:idx_audit_hotel_action,priority:1;index:idx_audit_hotel_entity,priority:1;index:idx_audit_hotel_seq,priority:1" json:"hotel_id"` // 登录时用户名不存在为 0
+	Actor      string       `gorm:"type:varchar(64);not null;index" json:"actor"`                                                                                                            // 操作人用户名，后台任务为 system
+	Action     string       `gorm:"type:varchar(64);not null;index:idx_audit_hotel_action,priority:2" json:"action"`
+	EntityType string       `gorm:"type:varchar(32);not null;index:idx_audit_hotel_entity,priority:2" json:"entity_type"`
+	EntityID   uint         `gorm:"not null;default:0;index:idx_audit_hotel_entity,priority:3" json:"entity_id"`
+	EntityName string       `gorm:"type:varchar(255)" json:"entity_name"` // 变更时的实体名称快照：行李为客人姓名，寄存室 / 格位为名称 / 编号
+	Changes    AuditChanges `gorm:"type:json" json:"changes"`             // 字段级差异，新建时 old 为 null
+	Note       string       `gorm:"type:varchar(255)" json:"note,omitempty"`
+	RequestID  string       `gorm:"type:varchar(64);index" json:"request_id,omitempty"`
+	ClientIP   string       `gorm:"type:varchar(64)" json:"client_ip,omitempty"`
+	CreatedAt  time.Time    `gorm:"index" json:"created_at"`
+
+	// 按酒店的哈希链：Seq 为酒店内连续序号，Hash 覆盖 PrevHash 和本条内容，修改或删除任意一条都会使链断开
+	Seq      uint64 `gorm:"not null;default:0;index:idx_audit_hotel_seq,priority:2" json:"seq"`
+	PrevHash string `gorm:"type:char(64);not null;default:''" json:"prev_hash"`
+	Hash     string `gorm:"type:char(64);not null;default:''" json:"hash"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// BeforeUpdate 审计事件写入后不允许修改
+func (AuditEvent) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete 审计事件写入后不允许删除
+func (AuditEvent) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// auditTimeLayout 计算哈希时的时间格式：统一转为 UTC，精确到毫秒（与 DATETIME(3) 列精度一致），哈希不受服务器时区影响
+const auditTimeLayout = "2006-01-02 15:04:05.000"
+
+// AuditTime 审计事件的时间，截断到毫秒，保证写入数据库后读回的值与计算哈希时一致
+func AuditTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond)
+}
+
+// ComputeHash 计算事件哈希：SHA-256(规范化 JSON(酒店、序号、上一条哈希和事件内容))
+func (e *AuditEvent) ComputeHash() string {
+	changes, _ := canonicalJSON(e.Changes)
+	content, _ := json.Marshal(struct {
+		HotelID    uint            `json:"hotel_id"`
+		Seq        uint64          `json:"seq"`
+		PrevHash   string          `json:"prev_hash"`
+		Actor      string          `json:"actor"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityID   uint            `json:"entity_id"`
+		EntityName string          `json:"entity_name"`
+		Changes    json.RawMessage `json:"changes"`
+		Note       string          `json:"note"`
+		RequestID  string          `json:"request_id"`
+		ClientIP   string          `json:"client_ip"`
+		CreatedAt  string          `json:"created_at"`
+	}{
+		HotelID:    e.HotelID,
+		Seq:        e.Seq,
+		PrevHash:   e.PrevHash,
+		Actor:      e.Actor,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		EntityName: e.EntityName,
+		Changes:    changes,
+		Note:       e.Note,
+		RequestID:  e.RequestID,
+		ClientIP:   e.ClientIP,
+		CreatedAt:  e.CreatedAt.UTC().Format(auditTimeLayout),
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON 先解析再序列化，数字、键顺序与数据库 JSON 列读回的值一致
+func canonicalJSON(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+	return json.Marshal(parsed)
+}
+
+// AuditChainHead 每个酒店哈希链的最新序号和哈希，追加事件时加行锁，同一酒店的事件按序写入
+type AuditChainHead struct {
+	HotelID   uint      `gorm:"primaryKey;autoIncrement:false" json:"hotel_id"`
+	Seq       uint64    `gorm:"not null;default:0" json:"seq"`
+	Hash      string    `gorm:"type:char(64);not null;default:''" json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (AuditChainHead) TableName() string {
+	return "audit_chain_heads"
+}
+
+// AuditCheckpoint 定期签名的链检查点：记录某一时刻链头的序号和哈希，签名使用服务端密钥（HMAC-SHA256），
+// 即使有人重算了整条链，也无法伪造与检查点一致的签名
+type AuditCheckpoint struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HotelID   uint      `gorm:"not null;index:idx_audit_checkpoint_hotel_seq,priority:1" json:"hotel_id"`
+	Seq       uint64    `gorm:"not null;index:idx_audit_checkpoint_hotel_seq,priority:2" json:"seq"`
+	Hash      string    `gorm:"type:char(64);not null" json:"hash"`
+	Signature string    `gorm:"type:char(64);not null" json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
+
+// BeforeUpdate 检查点写入后不允许修改
+func (AuditCheckpoint) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete 检查点写入后不允许删除
+func (AuditCheckpoint) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// AuditChange 单个字段的变更前后值
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges 字段名（JSON 名）-> 变更，以 JSON 存储
+type AuditChanges map[string]AuditChange
+
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		c = AuditChanges{}
+	}
+	b, err := json.Marshal(map[string]AuditChange(c))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (c *AuditChanges) Scan(value interface{}) error {
+	if c == nil {
+		return fmt.Errorf("AuditChanges: Scan on nil receiver")
+	}
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("AuditChanges: unsupported Scan type %T", value)
+	}
+	*c = AuditChanges{}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, c)
+}
+
+// NewValue 某字段变更后的值，没有变更时返回 nil
+func (c AuditChanges) NewValue(field string) interface{} {
+	return c[field].New
+}
+
+// StoreroomMove 移库事件的来源和目标寄存室
+func (c AuditChanges) StoreroomMove() (from uint, to uint) {
+	change := c["storeroom_id"]
+	return toUint(change.Old), toUint(change.New)
+}
+
+func toUint(v interface{}) uint {
+	switch n := v.(type) {
+	case float64:
+		return uint(n)
+	case uint:
+		return n
+	case int:
+		return uint(n)
+	}
+	return 0
+}
+
+// DiffFields 比较两个值的 JSON 表示，返回取值不同的字段；old 为 nil 时返回 new 的全部字段（新建）。
+// ignore 中的字段（如计算字段、更新时间）不参与比较
+func DiffFields(old, new interface{}, ignore ...string) AuditChanges {
+	oldFields := jsonFields(old)
+	newFields := jsonFields(new)
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	changes := AuditChanges{}
+	for field, value := range newFields {
+		if skip[field] {
+			continue
+		}
+		if before, ok := oldFields[field]; !ok || !reflect.DeepEqual(before, value) {
+			changes[field] = AuditChange{Old: before, New: value}
+		}
+	}
+	for field, value := range oldFields {
+		if _, ok := newFields[field]; !ok && !skip[field] {
+			changes[field] = AuditChange{Old: value, New: nil}
+		}
+	}
+	return changes
+}
+
+// AuditRedacted 只记录是否变更、不记录取值的字段在差异中的占位
+const AuditRedacted = "[redacted]"
+
+// LuggageRedactedFields 行李的客人联系方式：审计事件不受保留期清理，只记录是否变更，不保存取值
+var LuggageRedactedFields = []string{"contact_phone", "contact_email"}
+
+// Redact 把指定字段的新旧取值替换为占位；空值保持不变，仍能看出是新填写还是被清空
+func (c AuditChanges) Redact(fields ...string) AuditChanges {
+	for _, field := range fields {
+		if change, ok := c[field]; ok {
+			c[field] = AuditChange{Old: redactValue(change.Old), New: redactValue(change.New)}
+		}
+	}
+	return c
+}
+
+func redactValue(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	return AuditRedacted
+}
+
+// DiffJSON 与 DiffFields 相同，输入为 JSON 文本（用于迁移历史修改记录）
+func DiffJSON(old, new []byte, ignore ...string) AuditChanges {
+	return DiffFields(json.RawMessage(old), json.RawMessage(new), ignore...)
+}
+
+func jsonFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		if len(raw) == 0 {
+			return fields
+		}
+		_ = json.Unmarshal(raw, &fields)
+		return fields
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// CheckoutIdempotency 取件请求的幂等记录：同一 Idempotency-Key 重试时返回首次取件结果
+type CheckoutIdempotency struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	HotelID        uint      `gorm:"not null;uniqueIndex:idx_checkout_idempotency_key,priority:1" json:"hotel_id"`
+	IdempotencyKey string    `gorm:"type:varchar(128);not null;uniqueIndex:idx_checkout_idempotency_key,priority:2" json:"idempotency_key"`
+	RetrievalCode  string    `gorm:"type:varchar(32);not null" json:"retrieval_code"`
+	LuggageIDs     string    `gorm:"type:text" json:"luggage_ids"` // JSON 数组
+	RetrievedBy    string    `gorm:"type:varchar(64)" json:"retrieved_by"`
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+func (CheckoutIdempotency) TableName() string {
+	return "checkout_idempotency_keys"
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// 酒店设置默认值
+const (
+	DefaultHotelTimezone         = "Asia/Shanghai"
+	DefaultRetrievalCodeLength   = 6
+	DefaultRetrievalCodeAlphabet = "0123456789"
+	DefaultCheckoutMaxAttempts   = 5
+)
+
+// 取件二次验证方式
+const (
+	CheckoutVerifyNone       = "none"        // 只需取件码
+	CheckoutVerifyPhoneLast4 = "phone_last4" // 联系电话后 4 位（未登记电话时核对姓氏）
+	CheckoutVerifySurname    = "surname"     // 客人姓氏
+	CheckoutVerifyPIN        = "pin"         // 寄存时生成的一次性 PIN
+)
+
+// Hotel 酒店（租户），用户、寄存室、日志都通过 hotel_id 归属到酒店
+type Hotel struct {
+	ID                    uint      `gorm:"primaryKey" json:"id"`
+	Name                  string    `gorm:"type:varchar(128);not null" json:"name"`
+	Timezone              string    `gorm:"type:varchar(64);not null;default:Asia/Shanghai" json:"timezone"`
+	RetrievalCodeLength   int       `gorm:"not null;default:6" json:"retrieval_code_length"`                             // 取件码随机位数（不含末位校验位，实际长度为该值 + 1）
+	RetrievalCodeAlphabet string    `gorm:"type:varchar(36);not null;default:0123456789" json:"retrieval_code_alphabet"` // 取件码字符集
+	RetentionDays         int       `gorm:"not null;default:0" json:"retention_days"`                                    // 已取出行李记录保留天数，0 表示永久保留
+	CheckoutVerification  string    `gorm:"type:varchar(16);not null;default:none" json:"checkout_verification"`         // 取件二次验证方式
+	CheckoutMaxAttempts   int       `gorm:"not null;default:5" json:"checkout_max_attempts"`                             // 二次验证连续失败多少次后锁定取件码
+	NotifyGuests          bool      `gorm:"not null;default:false" json:"notify_guests"`                                 // 是否给客人发送寄存 / 取件通知
+	ReminderAfterHours    int       `gorm:"not null;default:0" json:"reminder_after_hours"`                              // 行李存放超过多少小时提醒客人领取，0 表示不提醒
+	OverdueAfterDays      int       `gorm:"not null;default:0" json:"overdue_after_days"`                                // 未填预计取件时间的行李存放超过多少天标记为逾期，0 表示只按预计取件时间判断
+	AbandonedAfterDays    int       `gorm:"not null;default:0" json:"abandoned_after_days"`                              // 逾期超过多少天标记为无人认领，0 表示不标记
+	IsActive              bool      `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func (Hotel) TableName() string {
+	return "hotels"
+}
+
+// Location 酒店所在时区，配置无效时回退到默认时区
+func (h *Hotel) Location() *time.Location {
+	if loc, err := time.LoadLocation(h.Timezone); err == nil {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultHotelTimezone); err == nil {
+		return loc
+	}
+	return time.Local
+}
@@ -4,6 +4,9 @@ import (
 	"time"
 )
 
+// 以下日志表已由 audit_events 取代，不再写入：历史数据在启动时迁移为审计事件，
+// 结构保留作为 /luggage/logs/* 视图的响应格式
+
 // StoredLog 寄存记录
 type StoredLog struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -46,3 +49,20 @@ type RetrievedLog struct {
 func (RetrievedLog) TableName() string {
 	return "retrieved_logs"
 }
+
+// MovedLog 移库记录：行李从一个寄存室移到另一个寄存室
+type MovedLog struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	HotelID         uint      `gorm:"not null;index" json:"hotel_id"`
+	LuggageID       uint      `gorm:"not null;index" json:"luggage_id"`
+	GuestName       string    `gorm:"not null" json:"guest_name"`
+	FromStoreroomID uint      `gorm:"not null" json:"from_storeroom_id"`
+	ToStoreroomID   uint      `gorm:"not null" json:"to_storeroom_id"`
+	MovedBy         string    `gorm:"not null" json:"moved_by"`
+	Note            string    `json:"note"`
+	MovedAt         time.Time `gorm:"autoCreateTime" json:"moved_at"`
+}
+
+func (MovedLog) TableName() string {
+	return "moved_logs"
+}
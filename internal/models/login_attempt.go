@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt 登录审计记录（成功与失败都记录；被限流 / 锁定的请求不记录，用户名不存在的失败按 IP 聚合）
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HotelID   uint      `gorm:"not null;default:0;index" json:"hotel_id"` // 用户名不存在时为 0
+	UserID    uint      `gorm:"not null;default:0" json:"user_id"`
+	Username  string    `gorm:"type:varchar(64);not null;index" json:"username"`
+	ClientIP  string    `gorm:"type:varchar(64);not null;index" json:"client_ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"type:varchar(64)" json:"reason"` // bad_credentials / account_disabled / locked / throttled
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// LoginThrottle 登录失败计数，按用户名（user:<name>）和 IP（ip:<addr>）分别统计；
+// unknown:<addr> 为该 IP 用户名不存在的失败次数，用于聚合登录记录
+type LoginThrottle struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Key          string     `gorm:"column:throttle_key;type:varchar(128);uniqueIndex;not null" json:"key"`
+	FailedCount  int        `gorm:"not null;default:0" json:"failed_count"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
@@ -1,19 +1,63 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// 行李尺寸分类，寄存室可按分类单独限制容量
+const (
+	SizeSmall    = "small"
+	SizeLarge    = "large"
+	SizeOversize = "oversize"
+)
+
+// SizeClasses 所有尺寸分类
+var SizeClasses = []string{SizeSmall, SizeLarge, SizeOversize}
+
+// 行李状态
+const (
+	LuggageStatusStored       = "stored"
+	LuggageStatusOverdue      = "overdue"        // 超过预计取件时间或酒店规定的存放天数，仍在寄存室
+	LuggageStatusAbandoned    = "abandoned"      // 逾期后长期无人领取，等待处置
+	LuggageStatusRetrieved    = "retrieved"
+	LuggageStatusDisposed     = "disposed"       // 已按酒店规定处置
+	LuggageStatusLostAndFound = "lost_and_found" // 已移交失物招领
+)
+
+// InStorageStatuses 仍在寄存室中的状态：占用容量，可以凭取件码取件
+var InStorageStatuses = []string{LuggageStatusStored, LuggageStatusOverdue, LuggageStatusAbandoned}
+
+// LuggageStatuses 所有行李状态
+var LuggageStatuses = []string{
+	LuggageStatusStored, LuggageStatusOverdue, LuggageStatusAbandoned,
+	LuggageStatusRetrieved, LuggageStatusDisposed, LuggageStatusLostAndFound,
+}
+
+// IsInStorage 行李是否仍在寄存室中
+func IsInStorage(status string) bool {
+	for _, s := range InStorageStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type Luggage struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
+	HotelID       uint      `gorm:"not null;default:0;index:idx_luggage_hotel_code_status,priority:1" json:"hotel_id"` // 冗余自寄存室，用于租户隔离
 	GuestName     string    `gorm:"not null" json:"guest_name"`
 	StaffName     string    `gorm:"not null" json:"staff_name"`
 	ContactPhone  string    `json:"contact_phone"`
 	ContactEmail  string    `json:"contact_email"`
+	RoomNumber    string    `gorm:"type:varchar(32);not null;default:'';index" json:"room_number"` // 可作排序列，不允许 NULL 以免游标分页跳过或重复
+	ReservationID string    `gorm:"type:varchar(64);index" json:"reservation_id"` // 预订号 / 账单（folio）号
 	Description   string    `json:"description"`
 	Quantity      int       `gorm:"default:1" json:"quantity"`
+	SizeClass     string    `gorm:"type:varchar(16);not null;default:small" json:"size_class"` // small, large, oversize
 	SpecialNotes  string    `json:"special_notes"`
 	// PhotoURLs stores multiple image URLs (recommended).
 	// Note: keep PhotoURL for backward compatibility (first image).
@@ -21,11 +65,17 @@ type Luggage struct {
 	PhotoURL      string    `json:"photo_url"`
 	StoreroomID   uint      `gorm:"not null" json:"storeroom_id"`
 	Storeroom     Storeroom `gorm:"foreignKey:StoreroomID" json:"-"`
-	RetrievalCode string    `gorm:"type:varchar(32);index;not null" json:"retrieval_code"` // 普通索引，允许多个行李共用同一个取件码
-	Status        string    `gorm:"not null;default:stored" json:"status"` // stored, retrieved
+	SlotID        *uint     `gorm:"index" json:"slot_id,omitempty"`                  // 所在格位，寄存室未划分格位时为空
+	SlotCode      string    `gorm:"type:varchar(32)" json:"slot_code,omitempty"`     // 格位编号快照，格位改名时同步更新在存行李
+	RetrievalCode string    `gorm:"type:varchar(32);index;index:idx_luggage_hotel_code_status,priority:2;not null" json:"retrieval_code"` // 普通索引，允许多个行李共用同一个取件码
+	Status        string    `gorm:"type:varchar(32);not null;default:stored;index:idx_luggage_hotel_code_status,priority:3" json:"status"` // stored, overdue, abandoned, retrieved, disposed, lost_and_found
 	StoredAt      time.Time `gorm:"autoCreateTime" json:"stored_at"`
+	ExpectedPickupAt *time.Time `gorm:"index" json:"expected_pickup_at,omitempty"` // 客人预计取件时间，超过后标记为逾期
+	OverdueAt     *time.Time `json:"overdue_at,omitempty"`
 	RetrievedAt   *time.Time `json:"retrieved_at,omitempty"`
 	RetrievedBy   string    `json:"retrieved_by,omitempty"`
+	DisposedAt    *time.Time `json:"disposed_at,omitempty"` // 处置或移交失物招领的时间
+	DisposedBy    string    `json:"disposed_by,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -34,3 +84,11 @@ type Luggage struct {
 func (Luggage) TableName() string {
 	return "luggages"
 }
+
+// BeforeCreate 行李记录必须归属某个酒店
+func (l *Luggage) BeforeCreate(tx *gorm.DB) error {
+	if l.HotelID == 0 {
+		return errors.New("luggage hotel_id is required")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// 客人通知事件
+const (
+	NotifyEventDeposit  = "deposit"  // 寄存成功：发送取件码和二维码链接
+	NotifyEventCheckout = "checkout" // 取件完成确认
+	NotifyEventReminder = "reminder" // 行李存放超过提醒时长
+)
+
+// NotifyEvents 所有通知事件
+var NotifyEvents = []string{NotifyEventDeposit, NotifyEventCheckout, NotifyEventReminder}
+
+// 通知渠道
+const (
+	NotifyChannelEmail = "email"
+	NotifyChannelSMS   = "sms"
+)
+
+// NotifyChannels 所有通知渠道
+var NotifyChannels = []string{NotifyChannelEmail, NotifyChannelSMS}
+
+// 通知发送状态
+const (
+	NotifyStatusSent   = "sent"
+	NotifyStatusFailed = "failed"
+)
+
+// NotificationTemplate 酒店自定义通知模板（text/template 语法），未自定义时使用系统默认模板
+type NotificationTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HotelID   uint      `gorm:"not null;uniqueIndex:idx_notification_template,priority:1" json:"hotel_id"`
+	Event     string    `gorm:"type:varchar(16);not null;uniqueIndex:idx_notification_template,priority:2" json:"event"`
+	Channel   string    `gorm:"type:varchar(16);not null;uniqueIndex:idx_notification_template,priority:3" json:"channel"`
+	Subject   string    `gorm:"type:varchar(255)" json:"subject"` // 仅邮件使用
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
+
+// NotificationLog 通知发送记录（不保存正文，正文可能含取件 PIN）
+type NotificationLog struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	HotelID       uint      `gorm:"not null;index:idx_notification_log_code,priority:1" json:"hotel_id"`
+	RetrievalCode string    `gorm:"type:varchar(32);not null;index:idx_notification_log_code,priority:2" json:"retrieval_code"`
+	Event         string    `gorm:"type:varchar(16);not null" json:"event"`
+	Channel       string    `gorm:"type:varchar(16);not null" json:"channel"`
+	Recipient     string    `gorm:"type:varchar(255);not null" json:"recipient"`
+	Status        string    `gorm:"type:varchar(16);not null" json:"status"` // sent, failed
+	Error         string    `gorm:"type:varchar(512)" json:"error,omitempty"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+func (NotificationLog) TableName() string {
+	return "notification_logs"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// ActiveRetrievalCode 正在使用中的取件码（仍有在存行李）。
+// (hotel_id, code) 唯一索引保证同一酒店的在用取件码不重复；行李全部取走后删除，取件码可再次分配。
+// 同时记录取件二次验证的失败次数和锁定状态
+type ActiveRetrievalCode struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	HotelID        uint       `gorm:"not null;uniqueIndex:idx_active_retrieval_code,priority:1" json:"hotel_id"`
+	Code           string     `gorm:"type:varchar(32);not null;uniqueIndex:idx_active_retrieval_code,priority:2" json:"code"`
+	PINHash        string     `gorm:"type:varchar(100)" json:"-"` // 一次性取件 PIN（bcrypt），仅 pin 验证方式使用
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`
+	LockedAt       *time.Time `json:"locked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (ActiveRetrievalCode) TableName() string {
+	return "active_retrieval_codes"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Session 登录会话，一次登录对应一个会话，access token 通过 sid 关联
+type Session struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	SessionID string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"session_id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	HotelID   uint       `gorm:"not null" json:"hotel_id"`
+	ClientIP  string     `gorm:"type:varchar(64)" json:"client_ip"`
+	UserAgent string     `gorm:"type:varchar(255)" json:"user_agent"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (Session) TableName() string {
+	return "user_sessions"
+}
+
+// RefreshToken 刷新令牌（只保存 SHA-256 摘要），每次刷新后轮换，旧令牌标记为已使用
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	SessionID string     `gorm:"type:varchar(64);index;not null" json:"session_id"`
+	TokenHash string     `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// StorageSlot 寄存室内的货架 / 格位，如 "A-03"。容量按件数（行李 quantity 之和）计算
+type StorageSlot struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	HotelID           uint      `gorm:"not null;index" json:"hotel_id"`
+	StoreroomID       uint      `gorm:"not null;uniqueIndex:idx_storage_slot_code,priority:1" json:"storeroom_id"`
+	Code              string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_storage_slot_code,priority:2" json:"code"`
+	Capacity          int       `gorm:"not null;default:1" json:"capacity"`
+	IsActive          bool      `gorm:"default:true" json:"is_active"`
+	StoredCount       int       `gorm:"-" json:"stored_count"`       // 计算字段，不存储
+	RemainingCapacity int       `gorm:"-" json:"remaining_capacity"` // 计算字段，不存储
+	CreatedAt         time.Time `json:"-"`
+	UpdatedAt         time.Time `json:"-"`
+}
+
+func (StorageSlot) TableName() string {
+	return "storage_slots"
+}
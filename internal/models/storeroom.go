@@ -11,10 +11,16 @@ type Storeroom struct {
 	HotelID           uint           `gorm:"not null" json:"hotel_id"`
 	Name              string         `gorm:"not null" json:"name"`
 	Location          string         `json:"location"`
-	Capacity          int            `gorm:"not null" json:"capacity"`
+	Capacity          int            `gorm:"not null" json:"capacity"` // 总容量，按件数（行李 quantity 之和）计算
+	SmallCapacity     *int           `json:"small_capacity"`           // 各尺寸分类容量，为空表示不单独限制
+	LargeCapacity     *int           `json:"large_capacity"`
+	OversizeCapacity  *int           `json:"oversize_capacity"`
 	IsActive          bool           `gorm:"default:true" json:"is_active"`
-	StoredCount       int            `gorm:"-" json:"stored_count"`      // 计算字段，不存储
-	RemainingCapacity int            `gorm:"-" json:"remaining_capacity"` // 计算字段，不存储
+	StoredCount       int            `gorm:"-" json:"stored_count"`                // 计算字段，不存储
+	RemainingCapacity int            `gorm:"-" json:"remaining_capacity"`          // 计算字段，不存储
+	StoredBySize      map[string]int `gorm:"-" json:"stored_by_size"`              // 计算字段，各尺寸分类已存件数
+	RemainingBySize   map[string]int `gorm:"-" json:"remaining_by_size,omitempty"` // 计算字段，仅包含设置了分类容量的尺寸
+	Slots             []StorageSlot  `gorm:"-" json:"slots,omitempty"`             // 计算字段，格位及各格位占用
 	CreatedAt         time.Time      `json:"-"`
 	UpdatedAt         time.Time      `json:"-"`
 	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
@@ -23,3 +29,34 @@ type Storeroom struct {
 func (Storeroom) TableName() string {
 	return "storerooms"
 }
+
+// SizeCapacity 某尺寸分类的容量，nil 表示只受总容量限制
+func (s *Storeroom) SizeCapacity(sizeClass string) *int {
+	switch sizeClass {
+	case SizeSmall:
+		return s.SmallCapacity
+	case SizeLarge:
+		return s.LargeCapacity
+	case SizeOversize:
+		return s.OversizeCapacity
+	}
+	return nil
+}
+
+// ApplyUsage 根据各尺寸分类已存件数填充计算字段
+func (s *Storeroom) ApplyUsage(storedBySize map[string]int) {
+	s.StoredCount = 0
+	s.StoredBySize = make(map[string]int, len(SizeClasses))
+	s.RemainingBySize = nil
+	for _, class := range SizeClasses {
+		s.StoredBySize[class] = storedBySize[class]
+		s.StoredCount += storedBySize[class]
+		if limit := s.SizeCapacity(class); limit != nil {
+			if s.RemainingBySize == nil {
+				s.RemainingBySize = make(map[string]int)
+			}
+			s.RemainingBySize[class] = *limit - storedBySize[class]
+		}
+	}
+	s.RemainingCapacity = s.Capacity - s.StoredCount
+}
@@ -1,11 +1,27 @@
 package models
 
+import (
+	"time"
+)
+
+// 用户角色
+const (
+	RoleSuperAdmin = "super_admin" // 平台管理员：管理酒店，不属于任何酒店（hotel_id = 0）
+	RoleAdmin      = "admin"       // 酒店管理员：本酒店全部权限
+	RoleManager    = "manager"     // 主管：寄存室管理、查看日志
+	RoleStaff      = "staff"       // 前台员工：寄存、取件、查询
+)
+
 type User struct {
-	ID       uint   `gorm:"primaryKey" json:"id"`
-	Username string `gorm:"type:varchar(64);uniqueIndex;not null" json:"username"`
-	Password string `gorm:"type:varchar(255);not null" json:"-"`
-	Role     string `gorm:"type:varchar(32);not null;default:staff" json:"role"`
-	HotelID  uint   `gorm:"not null" json:"hotel_id"`
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Username           string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"username"`
+	Password           string    `gorm:"type:varchar(255);not null" json:"-"`
+	Role               string    `gorm:"type:varchar(32);not null;default:staff" json:"role"`
+	HotelID            uint      `gorm:"not null" json:"hotel_id"`
+	IsActive           bool      `gorm:"not null;default:true" json:"is_active"`             // 停用后无法登录
+	MustChangePassword bool      `gorm:"not null;default:false" json:"must_change_password"` // 初始或被重置密码的账号登录后须先修改密码
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 func (User) TableName() string {
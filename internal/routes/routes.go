@@ -3,6 +3,7 @@ package routes
 import (
 	"luggage-sys2/internal/handlers"
 	"luggage-sys2/internal/middleware"
+	"luggage-sys2/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +17,9 @@ func SetupRoutes() *gin.Engine {
 	// 添加 CORS 中间件（必须在所有路由之前）
 	r.Use(middleware.CORSMiddleware())
 
+	// 请求 ID：写入审计事件并在响应头中回传
+	r.Use(middleware.RequestIDMiddleware())
+
 	// 静态资源访问：本地存储
 	// 访问示例：GET /uploads/2026/01/xxx.jpg
 	r.Static("/uploads", "./uploads")
@@ -27,18 +31,31 @@ func SetupRoutes() *gin.Engine {
 		})
 	})
 
+	// 取件码二维码（公开：内容只是取件码本身，便于 <img> 直接引用）
+	// 访问示例：GET /qr/123456?format=svg&size=300&level=H
+	qrcodeHandler := handlers.NewQRCodeHandler()
+	r.GET("/qr/:code", qrcodeHandler.GetQRCode)
+
 	// 认证处理器
 	authHandler := handlers.NewAuthHandler()
 
 	// API路由组
 	api := r.Group("/api")
 	{
-		// 登录（不需要认证）
+		// 登录、刷新 token（不需要认证）
 		api.POST("/login", authHandler.Login)
+		api.POST("/refresh", authHandler.RefreshToken)
 
 		// 需要认证的路由
 		api.Use(middleware.AuthMiddleware())
 		{
+			// 修改密码、登出（强制改密状态下也允许访问）
+			api.POST("/change_password", authHandler.ChangePassword)
+			api.POST("/logout", authHandler.Logout)
+
+			// 以下路由要求已完成强制改密
+			api.Use(middleware.PasswordChangedMiddleware())
+
 			// 图片上传（不影响行李接口结构：上传后把返回的 url 写入 photo_url）
 			uploadHandler := handlers.NewUploadHandler()
 			api.POST("/upload", uploadHandler.UploadImage)
@@ -51,19 +68,90 @@ func SetupRoutes() *gin.Engine {
 			api.GET("/luggage/:id/checkout", luggageHandler.GetGuestList)
 			api.GET("/luggage/list/by_guest_name", luggageHandler.GetLuggageByGuestName)
 			api.PUT("/luggage/:id", luggageHandler.UpdateLuggage)
+			api.GET("/luggage/overdue", luggageHandler.ListOverdueLuggage)
+			api.GET("/luggage/due", luggageHandler.ListDueLuggage)
+			api.GET("/luggage/search", luggageHandler.SearchLuggage)
+			api.POST("/luggage/codes/:code/items", luggageHandler.AppendLuggageItems)
+			api.POST("/luggage/:id/move", luggageHandler.MoveLuggage)
+
+			// 寄存凭条与行李标签打印
+			ticketHandler := handlers.NewTicketHandler()
+			api.GET("/luggage/:id/ticket", ticketHandler.GetClaimTicket)
+
+			// 当前酒店信息与设置
+			hotelHandler := handlers.NewHotelHandler()
+			api.GET("/hotel", hotelHandler.GetCurrentHotel)
 
 			// 寄存室相关路由
 			storeroomHandler := handlers.NewStoreroomHandler()
 			api.GET("/luggage/storerooms", storeroomHandler.ListStorerooms)
-			api.POST("/luggage/storerooms", storeroomHandler.CreateStoreroom)
-			api.PUT("/luggage/storerooms/:id", storeroomHandler.UpdateStoreroom)
 			api.GET("/luggage/storerooms/:id/orders", storeroomHandler.GetStoreroomOrders)
+			api.GET("/luggage/storerooms/:id/slots", storeroomHandler.ListSlots)
+
+			// 管理类路由：仅 admin / manager 可访问，staff 只能寄存、取件和查询
+			manage := api.Group("", middleware.RequireRole(models.RoleAdmin, models.RoleManager))
+			{
+				manage.POST("/luggage/storerooms", storeroomHandler.CreateStoreroom)
+				manage.PUT("/luggage/storerooms/:id", storeroomHandler.UpdateStoreroom)
+				manage.PATCH("/luggage/storerooms/:id", storeroomHandler.UpdateStoreroom)
+				manage.DELETE("/luggage/storerooms/:id", storeroomHandler.DeleteStoreroom)
+				manage.POST("/luggage/storerooms/:id/transfer", storeroomHandler.TransferLuggage)
+				manage.POST("/luggage/storerooms/:id/slots", storeroomHandler.CreateSlots)
+				manage.PATCH("/luggage/storerooms/:id/slots/:slot_id", storeroomHandler.UpdateSlot)
+				manage.POST("/luggage/codes/:code/unlock", luggageHandler.UnlockRetrievalCode)
+				manage.POST("/luggage/:id/dispose", luggageHandler.DisposeLuggage)
+				manage.POST("/luggage/:id/lost_and_found", luggageHandler.TransferToLostAndFound)
+
+				// 日志相关路由
+				logHandler := handlers.NewLogHandler()
+				manage.GET("/luggage/logs/stored", logHandler.GetStoredLogs)
+				manage.GET("/luggage/logs/updated", logHandler.GetUpdatedLogs)
+				manage.GET("/luggage/logs/retrieved", logHandler.GetRetrievedLogs)
+				manage.GET("/luggage/logs/moved", logHandler.GetMovedLogs)
+
+				// 审计事件
+				auditHandler := handlers.NewAuditHandler()
+				manage.GET("/audit_events", auditHandler.ListEvents)
+
+				// 客人通知发送记录
+				notificationHandler := handlers.NewNotificationHandler()
+				manage.GET("/notification_logs", notificationHandler.ListLogs)
+			}
+
+			// 用户管理：仅 admin，只能管理本酒店账号
+			admin := api.Group("", middleware.RequireRole(models.RoleAdmin))
+			{
+				userHandler := handlers.NewUserHandler()
+				admin.GET("/users", userHandler.ListUsers)
+				admin.POST("/users", userHandler.CreateUser)
+				admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
+				admin.PUT("/users/:id/status", userHandler.UpdateUserStatus)
+				admin.POST("/users/:id/reset_password", userHandler.ResetPassword)
+				admin.POST("/users/:id/revoke_sessions", userHandler.RevokeUserSessions)
+				admin.GET("/users/lockouts", userHandler.ListLockouts)
+				admin.POST("/users/:id/unlock", userHandler.UnlockUser)
+				admin.GET("/login_attempts", userHandler.ListFailedLogins)
+
+				admin.PUT("/hotel/settings", hotelHandler.UpdateCurrentHotelSettings)
+
+				// 审计哈希链校验（遍历整条链，仅 admin）
+				auditHandler := handlers.NewAuditHandler()
+				admin.GET("/audit_events/verify", auditHandler.VerifyChain)
+
+				// 客人通知模板
+				notificationHandler := handlers.NewNotificationHandler()
+				admin.GET("/notification_templates", notificationHandler.ListTemplates)
+				admin.PUT("/notification_templates/:event/:channel", notificationHandler.SaveTemplate)
+				admin.DELETE("/notification_templates/:event/:channel", notificationHandler.ResetTemplate)
+			}
 
-			// 日志相关路由
-			logHandler := handlers.NewLogHandler()
-			api.GET("/luggage/logs/stored", logHandler.GetStoredLogs)
-			api.GET("/luggage/logs/updated", logHandler.GetUpdatedLogs)
-			api.GET("/luggage/logs/retrieved", logHandler.GetRetrievedLogs)
+			// 酒店（租户）管理：仅平台管理员
+			super := api.Group("", middleware.RequireRole(models.RoleSuperAdmin))
+			{
+				super.GET("/hotels", hotelHandler.ListHotels)
+				super.POST("/hotels", hotelHandler.CreateHotel)
+				super.PUT("/hotels/:id", hotelHandler.UpdateHotel)
+			}
 		}
 	}
 
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+)
+
+// auditVerifyBatchSize 校验哈希链时每次读取的事件数
+const auditVerifyBatchSize = 1000
+
+// ErrAuditSigningKeyMissing 未配置 AUDIT_SIGNING_KEY，无法签名或校验检查点
+var ErrAuditSigningKeyMissing = errors.New("AUDIT_SIGNING_KEY is not configured, audit checkpoints cannot be signed or verified")
+
+// 哈希链断开的原因
+const (
+	AuditBreakMissingEvent       = "missing_event"                // 序号不连续：事件被删除
+	AuditBreakDuplicateSeq       = "duplicate_seq"                // 序号重复：事件被插入或复制
+	AuditBreakPrevHashMismatch   = "prev_hash_mismatch"           // 与上一条的链接不一致：事件被替换或重排
+	AuditBreakHashMismatch       = "hash_mismatch"                // 内容与哈希不一致：事件被修改
+	AuditBreakHeadMismatch       = "head_mismatch"                // 链头与最后一条事件不一致：末尾事件被删除或链头被改动
+	AuditBreakUnchained          = "unchained_event"              // 未入链的事件：绕过应用直接写入
+	AuditBreakCheckpointInvalid  = "checkpoint_signature_invalid" // 检查点签名不正确：检查点被伪造或修改
+	AuditBreakCheckpointMismatch = "checkpoint_mismatch"          // 检查点与该序号事件的哈希不一致：链被整体重算或截断
+)
+
+// AuditChainBreak 哈希链第一处断开的位置
+type AuditChainBreak struct {
+	Seq     uint64 `json:"seq"`
+	EventID uint   `json:"event_id,omitempty"` // 事件已被删除时为空
+	Reason  string `json:"reason"`
+}
+
+// AuditChainReport 哈希链校验结果
+type AuditChainReport struct {
+	HotelID          uint                    `json:"hotel_id"`
+	Valid            bool                    `json:"valid"`
+	Checked          int64                   `json:"checked"` // 已校验的事件数
+	HeadSeq          uint64                  `json:"head_seq"`
+	HeadHash         string                  `json:"head_hash"`
+	Checkpoints      int                     `json:"checkpoints"` // 已校验的检查点数
+	LatestCheckpoint *models.AuditCheckpoint `json:"latest_checkpoint,omitempty"`
+	Break            *AuditChainBreak        `json:"break,omitempty"`
+}
+
+// signAuditCheckpoint 检查点签名：HMAC-SHA256(密钥, "酒店:序号:哈希")
+func signAuditCheckpoint(hotelID uint, seq uint64, hash string) string {
+	mac := hmac.New(sha256.New, []byte(config.AuditSigningKey))
+	fmt.Fprintf(mac, "%d:%d:%s", hotelID, seq, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validAuditCheckpoint(cp models.AuditCheckpoint) bool {
+	expected := signAuditCheckpoint(cp.HotelID, cp.Seq, cp.Hash)
+	return hmac.Equal([]byte(expected), []byte(cp.Signature))
+}
+
+// walkAuditChain 按序号校验 (afterSeq, toSeq] 区间内的事件，afterHash 为 afterSeq 处事件的哈希（从头校验时为空）。
+// 每条事件通过链校验后调用 onEvent（可为 nil）做额外检查；返回校验的事件数、最后一条的哈希和第一处断开
+func walkAuditChain(hotelID uint, afterSeq uint64, afterHash string, toSeq uint64, onEvent func(models.AuditEvent) *AuditChainBreak) (int64, string, *AuditChainBreak, error) {
+	expected, prevHash := afterSeq+1, afterHash
+	var checked int64
+	var lastID uint
+	for {
+		query := database.DB.Where("hotel_id = ? AND seq <= ?", hotelID, toSeq)
+		if lastID == 0 {
+			query = query.Where("seq > ?", afterSeq)
+		} else {
+			// 按 (seq, id) 翻页，重复的序号不会被跳过
+			query = query.Where("(seq > ? OR (seq = ? AND id > ?))", expected-1, expected-1, lastID)
+		}
+		var events []models.AuditEvent
+		if err := query.Order("seq ASC, id ASC").Limit(auditVerifyBatchSize).Find(&events).Error; err != nil {
+			return checked, prevHash, nil, err
+		}
+
+		for _, e := range events {
+			switch {
+			case e.Seq > expected:
+				return checked, prevHash, &AuditChainBreak{Seq: expected, Reason: AuditBreakMissingEvent}, nil
+			case e.Seq < expected:
+				return checked, prevHash, &AuditChainBreak{Seq: e.Seq, EventID: e.ID, Reason: AuditBreakDuplicateSeq}, nil
+			case e.PrevHash != prevHash:
+				return checked, prevHash, &AuditChainBreak{Seq: e.Seq, EventID: e.ID, Reason: AuditBreakPrevHashMismatch}, nil
+			case e.ComputeHash() != e.Hash:
+				return checked, prevHash, &AuditChainBreak{Seq: e.Seq, EventID: e.ID, Reason: AuditBreakHashMismatch}, nil
+			}
+			if onEvent != nil {
+				if brk := onEvent(e); brk != nil {
+					return checked, prevHash, brk, nil
+				}
+			}
+			checked++
+			expected++
+			prevHash = e.Hash
+			lastID = e.ID
+		}
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+
+	// 链头之前的事件不足：末尾事件被删除
+	if expected <= toSeq {
+		return checked, prevHash, &AuditChainBreak{Seq: expected, Reason: AuditBreakMissingEvent}, nil
+	}
+	return checked, prevHash, nil, nil
+}
+
+// VerifyChain 从头校验酒店的审计哈希链和全部检查点，报告第一处断开
+func (s *AuditService) VerifyChain(hotelID uint) (*AuditChainReport, error) {
+	// 先读检查点再读链头：读到的检查点都不会超过链头
+	var checkpoints []models.AuditCheckpoint
+	if err := database.DB.Where("hotel_id = ?", hotelID).Order("seq ASC, id ASC").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	if len(checkpoints) > 0 && config.AuditSigningKey == "" {
+		return nil, ErrAuditSigningKeyMissing
+	}
+	var head models.AuditChainHead
+	if err := database.DB.Where("hotel_id = ?", hotelID).Limit(1).Find(&head).Error; err != nil {
+		return nil, err
+	}
+
+	report := &AuditChainReport{HotelID: hotelID, HeadSeq: head.Seq, HeadHash: head.Hash}
+	if len(checkpoints) > 0 {
+		report.LatestCheckpoint = &checkpoints[len(checkpoints)-1]
+	}
+
+	bySeq := make(map[uint64][]models.AuditCheckpoint, len(checkpoints))
+	for _, cp := range checkpoints {
+		bySeq[cp.Seq] = append(bySeq[cp.Seq], cp)
+	}
+	checked, tail, brk, err := walkAuditChain(hotelID, 0, "", head.Seq, func(e models.AuditEvent) *AuditChainBreak {
+		for _, cp := range bySeq[e.Seq] {
+			if !validAuditCheckpoint(cp) {
+				return &AuditChainBreak{Seq: e.Seq, EventID: e.ID, Reason: AuditBreakCheckpointInvalid}
+			}
+			if cp.Hash != e.Hash {
+				return &AuditChainBreak{Seq: e.Seq, EventID: e.ID, Reason: AuditBreakCheckpointMismatch}
+			}
+			report.Checkpoints++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Checked = checked
+
+	if brk == nil && tail != head.Hash {
+		brk = &AuditChainBreak{Seq: head.Seq, Reason: AuditBreakHeadMismatch}
+	}
+	// 检查点超出链头：链头连同末尾事件被回退
+	if brk == nil && len(checkpoints) > 0 && report.LatestCheckpoint.Seq > head.Seq {
+		brk = &AuditChainBreak{Seq: report.LatestCheckpoint.Seq, Reason: AuditBreakCheckpointMismatch}
+	}
+	if brk == nil {
+		var unchained models.AuditEvent
+		if err := database.DB.Where("hotel_id = ? AND seq = 0", hotelID).Order("id ASC").Limit(1).Find(&unchained).Error; err != nil {
+			return nil, err
+		}
+		if unchained.ID != 0 {
+			brk = &AuditChainBreak{EventID: unchained.ID, Reason: AuditBreakUnchained}
+		}
+	}
+
+	report.Break = brk
+	report.Valid = brk == nil
+	return report, nil
+}
+
+// ChainHotelIDs 需要校验的酒店：已有审计哈希链的（包括用户名不存在的登录事件所在的 0），
+// 以及还没有链头但存在未入链事件的（会被报告为 unchained_event）
+func (s *AuditService) ChainHotelIDs() ([]uint, error) {
+	var hotelIDs []uint
+	err := database.DB.Raw(`
+		SELECT hotel_id FROM audit_chain_heads
+		UNION
+		SELECT DISTINCT hotel_id FROM audit_events WHERE seq = 0
+		ORDER BY hotel_id ASC
+	`).Scan(&hotelIDs).Error
+	return hotelIDs, err
+}
+
+// CreateCheckpoints 为上次检查点之后有新事件的酒店生成签名检查点；
+// 签名前先校验新增的区间，链已断开时不签名，避免为篡改后的链背书
+func (s *AuditService) CreateCheckpoints() (int, error) {
+	if config.AuditSigningKey == "" {
+		return 0, ErrAuditSigningKeyMissing
+	}
+	var heads []models.AuditChainHead
+	if err := database.DB.Where("seq > 0").Find(&heads).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, head := range heads {
+		var last models.AuditCheckpoint
+		if err := database.DB.Where("hotel_id = ?", head.HotelID).Order("seq DESC, id DESC").Limit(1).Find(&last).Error; err != nil {
+			return created, err
+		}
+		if last.Seq >= head.Seq {
+			continue
+		}
+		if last.ID != 0 && !validAuditCheckpoint(last) {
+			log.Printf("Audit checkpoint %d of hotel %d has an invalid signature, checkpoint skipped", last.ID, head.HotelID)
+			continue
+		}
+
+		_, tail, brk, err := walkAuditChain(head.HotelID, last.Seq, last.Hash, head.Seq, nil)
+		if err != nil {
+			return created, err
+		}
+		if brk == nil && tail != head.Hash {
+			brk = &AuditChainBreak{Seq: head.Seq, Reason: AuditBreakHeadMismatch}
+		}
+		if brk != nil {
+			log.Printf("Audit chain of hotel %d broken at seq %d (%s), checkpoint skipped", head.HotelID, brk.Seq, brk.Reason)
+			continue
+		}
+
+		checkpoint := models.AuditCheckpoint{
+			HotelID:   head.HotelID,
+			Seq:       head.Seq,
+			Hash:      head.Hash,
+			Signature: signAuditCheckpoint(head.HotelID, head.Seq, head.Hash),
+		}
+		if err := database.DB.Create(&checkpoint).Error; err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// StartAuditCheckpointJob 后台定期为审计哈希链生成签名检查点（启动后执行一次，之后按 AUDIT_CHECKPOINT_INTERVAL）；
+// 未配置 AUDIT_SIGNING_KEY 时不启动
+func StartAuditCheckpointJob() {
+	if config.AuditSigningKey == "" {
+		log.Printf("Warning: AUDIT_SIGNING_KEY is not set, audit checkpoint job not started")
+		return
+	}
+	go func() {
+		for {
+			if n, err := NewAuditService().CreateCheckpoints(); err != nil {
+				log.Printf("Audit checkpoint job failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Audit checkpoint job signed %d checkpoints", n)
+			}
+			time.Sleep(config.AuditCheckpointInterval)
+		}
+	}()
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Actor 发起变更的操作人和请求信息，写入审计事件
+type Actor struct {
+	Username  string
+	RequestID string
+	ClientIP  string
+}
+
+// systemAuditActor 后台任务的操作人
+var systemAuditActor = Actor{Username: systemActor}
+
+// 修改行李 / 寄存室时不记录的字段：更新时间和计算字段
+var (
+	luggageAuditIgnore   = []string{"created_at", "updated_at"}
+	storeroomAuditIgnore = []string{"stored_count", "remaining_capacity", "stored_by_size", "remaining_by_size", "slots"}
+	slotAuditIgnore      = []string{"stored_count", "remaining_capacity"}
+)
+
+// auditRecord 一条待写入的审计事件
+type auditRecord struct {
+	Action     string
+	EntityType string
+	EntityID   uint
+	EntityName string
+	Changes    models.AuditChanges
+	Note       string
+}
+
+// recordAudit 在调用方的事务中写入审计事件并接入酒店哈希链，写入失败时整个变更回滚。
+// 会锁定哈希链头，应作为事务中的最后一次写入
+func recordAudit(tx *gorm.DB, hotelID uint, actor Actor, records ...auditRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	events := make([]models.AuditEvent, 0, len(records))
+	for _, r := range records {
+		if r.Changes == nil {
+			r.Changes = models.AuditChanges{}
+		}
+		events = append(events, models.AuditEvent{
+			HotelID:    hotelID,
+			Actor:      truncate(actor.Username, 64),
+			Action:     r.Action,
+			EntityType: r.EntityType,
+			EntityID:   r.EntityID,
+			EntityName: truncate(r.EntityName, 255),
+			Changes:    r.Changes,
+			Note:       truncate(r.Note, 255),
+			RequestID:  truncate(actor.RequestID, 64),
+			ClientIP:   truncate(actor.ClientIP, 64),
+		})
+	}
+	return database.AppendAuditEvents(tx, hotelID, events)
+}
+
+// truncate 按字节截断到 n 以内，不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// luggageAudit 行李变更的审计事件，old 为 nil 表示新寄存
+func luggageAudit(action string, old *models.Luggage, luggage models.Luggage, note string) auditRecord {
+	var changes models.AuditChanges
+	if old == nil {
+		changes = models.DiffFields(nil, luggage, luggageAuditIgnore...)
+	} else {
+		changes = models.DiffFields(*old, luggage, luggageAuditIgnore...)
+	}
+	changes.Redact(models.LuggageRedactedFields...)
+	return auditRecord{
+		Action:     action,
+		EntityType: models.AuditEntityLuggage,
+		EntityID:   luggage.ID,
+		EntityName: luggage.GuestName,
+		Changes:    changes,
+		Note:       note,
+	}
+}
+
+type AuditService struct{}
+
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// AuditQuery 审计事件查询条件，未设置的字段不过滤；操作人、时间范围、分页和排序使用 ListQuery
+type AuditQuery struct {
+	Actions    []string
+	EntityType string
+	EntityID   uint
+	RequestID  string
+}
+
+// auditEventSpec 审计事件列表的过滤列和排序列
+var auditEventSpec = listSpec{
+	timeColumn:      "created_at",
+	guestColumn:     "entity_name",
+	staffColumn:     "actor",
+	luggageIDColumn: "entity_id",
+	sortColumns:     map[string]string{"created_at": "created_at", "actor": "actor"},
+}
+
+func auditEventSortKey(e models.AuditEvent, sort string) (interface{}, uint) {
+	if sort == "actor" {
+		return e.Actor, e.ID
+	}
+	return e.CreatedAt, e.ID
+}
+
+// ListEvents 本酒店的审计事件，游标分页
+func (s *AuditService) ListEvents(hotelID uint, filter AuditQuery, q ListQuery) (*ListPage[models.AuditEvent], error) {
+	query := database.DB.Model(&models.AuditEvent{}).Where("hotel_id = ?", hotelID)
+	if len(filter.Actions) > 0 {
+		query = query.Where("action IN ?", filter.Actions)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		if filter.EntityType == "" {
+			return nil, errors.New("entity_id requires entity_type")
+		}
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", strings.TrimSpace(filter.RequestID))
+	}
+	// luggage_id 只匹配行李事件
+	if q.LuggageID != 0 {
+		query = query.Where("entity_type = ?", models.AuditEntityLuggage)
+	}
+	return paginate(query, hotelID, q, auditEventSpec, auditEventSortKey)
+}
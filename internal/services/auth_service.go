@@ -1,6 +1,8 @@
 package services
 
 import (
+	"errors"
+
 	"luggage-sys2/internal/database"
 	"luggage-sys2/internal/models"
 	"luggage-sys2/internal/utils"
@@ -12,20 +14,97 @@ func NewAuthService() *AuthService {
 	return &AuthService{}
 }
 
-func (s *AuthService) Login(username, password string) (*models.User, string, error) {
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrWrongPassword   = errors.New("old password is incorrect")
+)
+
+// dummyPasswordHash 用户名不存在时用于比对的 bcrypt 哈希（与 utils.HashPassword 相同的成本），
+// 两种失败都执行一次 bcrypt 比对，响应时间不暴露用户名是否存在
+const dummyPasswordHash = "$2a$10$QpoWKq1izC5F.Kiqw./QWOIUO5zhd8VEzu7LiJ3uh/4GrGgATJYF6"
+
+// Login 校验用户名密码并创建会话。
+// 连续失败会触发退避或锁定（返回 *LoginBlockedError，不写入登录记录）；其他尝试写入登录记录和审计事件
+func (s *AuthService) Login(username, password, clientIP, userAgent, requestID string) (*models.User, *TokenPair, error) {
+	guard := NewLoginGuardService()
+	actor := Actor{Username: username, RequestID: requestID, ClientIP: clientIP}
+
+	if err := guard.Acquire(username, clientIP); err != nil {
+		return nil, nil, err
+	}
+
 	var user models.User
 	if err := database.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		return nil, "", err
+		utils.CheckPassword(password, dummyPasswordHash)
+		guard.RecordFailure(nil, actor, userAgent, LoginReasonBadCredentials)
+		return nil, nil, err
 	}
 
 	if !utils.CheckPassword(password, user.Password) {
-		return nil, "", nil
+		guard.RecordFailure(&user, actor, userAgent, LoginReasonBadCredentials)
+		return nil, nil, nil
+	}
+	guard.Release(username, clientIP)
+
+	if !user.IsActive {
+		guard.RecordFailure(&user, actor, userAgent, LoginReasonAccountDisabled)
+		return nil, nil, ErrAccountDisabled
+	}
+
+	// 平台管理员不属于任何酒店；其他账号所属酒店必须存在且启用
+	if user.Role != models.RoleSuperAdmin {
+		hotel, err := NewHotelService().GetHotel(user.HotelID)
+		if err != nil || !hotel.IsActive {
+			guard.RecordFailure(&user, actor, userAgent, LoginReasonHotelDisabled)
+			return nil, nil, ErrHotelDisabled
+		}
+	}
+
+	guard.RecordSuccess(&user, actor, userAgent)
+
+	tokens, err := NewSessionService().CreateSession(&user, clientIP, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &user, tokens, nil
+}
+
+// ChangePassword 用户修改自己的密码，成功后返回新的 token（清除强制改密标记），
+// 并吊销该用户的其他会话
+func (s *AuthService) ChangePassword(userID uint, sessionID, oldPassword, newPassword string) (string, error) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return "", errors.New("user not found")
+	}
+
+	if !utils.CheckPassword(oldPassword, user.Password) {
+		return "", ErrWrongPassword
+	}
+	if err := validatePassword(newPassword); err != nil {
+		return "", err
+	}
+	if oldPassword == newPassword {
+		return "", errors.New("new password must be different from old password")
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Username, user.HotelID)
+	hashedPassword, err := utils.HashPassword(newPassword)
 	if err != nil {
-		return nil, "", err
+		return "", err
+	}
+
+	user.Password = hashedPassword
+	user.MustChangePassword = false
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"password":             user.Password,
+		"must_change_password": false,
+	}).Error; err != nil {
+		return "", errors.New("change password failed")
+	}
+
+	if _, err := NewSessionService().RevokeUserSessions(user.ID, sessionID); err != nil {
+		return "", err
 	}
 
-	return &user, token, nil
+	return utils.GenerateToken(&user, sessionID)
 }
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+	"luggage-sys2/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrVerificationRequired = errors.New("guest verification is required for checkout")
+	ErrRetrievalCodeLocked  = errors.New("retrieval code is locked after too many failed verification attempts, ask a manager to unlock it")
+)
+
+// VerificationFailedError 取件二次验证未通过
+type VerificationFailedError struct {
+	RemainingAttempts int
+}
+
+func (e *VerificationFailedError) Error() string {
+	return fmt.Sprintf("guest verification failed, %d attempts remaining", e.RemainingAttempts)
+}
+
+// CheckoutLuggageRequest 取件请求体（可选），酒店启用二次验证时需要提供 verification；
+// 只取走部分行李时传 luggage_ids 或 item_indexes（二选一），都不传时取走取件码下全部在存行李
+type CheckoutLuggageRequest struct {
+	Verification string `json:"verification"` // 电话后 4 位 / 姓氏 / PIN，取决于酒店设置
+	LuggageIDs   []uint `json:"luggage_ids"`  // 要取走的行李 ID
+	ItemIndexes  []int  `json:"item_indexes"` // 要取走的行李序号（从 1 开始，与按取件码查询的返回顺序、行李标签编号一致）
+}
+
+// isVerificationError 是否为二次验证未通过（而不是数据库等错误）
+func isVerificationError(err error) bool {
+	var failed *VerificationFailedError
+	return errors.Is(err, ErrVerificationRequired) || errors.Is(err, ErrRetrievalCodeLocked) || errors.As(err, &failed)
+}
+
+// verifyCheckout 在取件事务内核对客人二次验证信息（调用方已锁定行李行），
+// 验证失败时累加失败次数，达到上限后锁定取件码
+func (s *LuggageService) verifyCheckout(tx *gorm.DB, hotel *models.Hotel, code string, luggage models.Luggage, input string) error {
+	if hotel.CheckoutVerification == "" || hotel.CheckoutVerification == models.CheckoutVerifyNone {
+		return nil
+	}
+
+	var record models.ActiveRetrievalCode
+	err := tx.Scopes(database.HotelScope(hotel.ID)).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", code).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 历史数据没有登记取件码时补登记，用于统计失败次数
+		record = models.ActiveRetrievalCode{HotelID: hotel.ID, Code: code}
+		err = tx.Create(&record).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if record.LockedAt != nil {
+		return ErrRetrievalCodeLocked
+	}
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return ErrVerificationRequired
+	}
+
+	if matchVerification(hotel.CheckoutVerification, record, luggage, input) {
+		if record.FailedAttempts > 0 {
+			if err := tx.Model(&record).Scopes(database.HotelScope(hotel.ID)).
+				Update("failed_attempts", 0).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 验证失败：累加失败次数
+	attempts := record.FailedAttempts + 1
+	updates := map[string]interface{}{"failed_attempts": attempts}
+	locked := attempts >= hotel.CheckoutMaxAttempts
+	if locked {
+		updates["locked_at"] = time.Now()
+	}
+	if err := tx.Model(&record).Scopes(database.HotelScope(hotel.ID)).Updates(updates).Error; err != nil {
+		return err
+	}
+	if locked {
+		return ErrRetrievalCodeLocked
+	}
+	return &VerificationFailedError{RemainingAttempts: hotel.CheckoutMaxAttempts - attempts}
+}
+
+// matchVerification 按验证方式核对客人提供的信息；缺少对应资料时（未登记电话、启用 PIN 之前寄存的行李）改为核对姓氏
+func matchVerification(mode string, record models.ActiveRetrievalCode, luggage models.Luggage, input string) bool {
+	switch mode {
+	case models.CheckoutVerifyPhoneLast4:
+		if last4 := phoneLast4(luggage.ContactPhone); last4 != "" {
+			return input == last4
+		}
+	case models.CheckoutVerifyPIN:
+		if record.PINHash != "" {
+			return utils.CheckPassword(input, record.PINHash)
+		}
+	}
+	return matchSurname(luggage.GuestName, input)
+}
+
+// phoneLast4 联系电话中数字的后 4 位，不足 4 位时返回空
+func phoneLast4(phone string) string {
+	digits := make([]rune, 0, len(phone))
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		}
+	}
+	if len(digits) < 4 {
+		return ""
+	}
+	return string(digits[len(digits)-4:])
+}
+
+// matchSurname 核对姓氏（也接受完整姓名），不区分大小写：多个单词的姓名匹配首个或最后一个单词；
+// 只有中文姓名才接受开头的 1~2 个字（兼容复姓），其他单个单词的姓名必须完整匹配
+func matchSurname(guestName, input string) bool {
+	guestName = strings.TrimSpace(guestName)
+	input = strings.TrimSpace(input)
+	if guestName == "" || input == "" {
+		return false
+	}
+	if strings.EqualFold(guestName, input) {
+		return true
+	}
+	if fields := strings.Fields(guestName); len(fields) > 1 {
+		return strings.EqualFold(fields[0], input) || strings.EqualFold(fields[len(fields)-1], input)
+	}
+	if !isHanName(guestName) {
+		return false
+	}
+	inputRunes := []rune(input)
+	return len(inputRunes) <= 2 && len(inputRunes) < len([]rune(guestName)) && strings.HasPrefix(guestName, input)
+}
+
+// isHanName 姓名是否全部由汉字组成
+func isHanName(name string) bool {
+	for _, r := range name {
+		if !unicode.Is(unicode.Han, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnlockRetrievalCode 管理员解除取件码锁定并清零失败次数
+func (s *LuggageService) UnlockRetrievalCode(code string, hotelID uint) error {
+	code = normalizeRetrievalCode(code)
+	result := database.DB.Model(&models.ActiveRetrievalCode{}).Scopes(database.HotelScope(hotelID)).
+		Where("code = ?", code).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_at": nil})
+	if result.Error != nil {
+		return errors.New("unlock retrieval code failed")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("retrieval code not found in this hotel")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import "testing"
+
+func TestMatchSurname(t *testing.T) {
+	tests := []struct {
+		name      string
+		guestName string
+		input     string
+		want      bool
+	}{
+		{"latin full name", "Smith", "Smith", true},
+		{"latin case-insensitive", "Smith", "smith", true},
+		{"latin one-letter prefix", "Smith", "S", false},
+		{"latin two-letter prefix", "Smith", "Sm", false},
+		{"latin lowercase prefix", "Smith", "sm", false},
+		{"latin longer prefix", "Smith", "Smit", false},
+		{"latin other name", "Smith", "Jones", false},
+		{"latin single letter name", "O", "O", true},
+		{"accented single word", "Élodie", "É", false},
+		{"multi-word first word", "John Smith", "john", true},
+		{"multi-word last word", "John Smith", "SMITH", true},
+		{"multi-word full name", "John Smith", "john smith", true},
+		{"multi-word middle word", "John Paul Smith", "Paul", false},
+		{"multi-word prefix", "John Smith", "Sm", false},
+		{"multi-word first letter", "John Smith", "J", false},
+		{"cjk one-char surname", "张三", "张", true},
+		{"cjk two-char compound surname", "欧阳娜娜", "欧阳", true},
+		{"cjk full name", "张三", "张三", true},
+		{"cjk wrong surname", "张三", "李", false},
+		{"cjk given name", "张三", "三", false},
+		{"cjk three-char prefix", "欧阳娜娜", "欧阳娜", false},
+		{"cjk whole two-char name prefix", "张三", "张三丰", false},
+		{"cjk multi-word", "欧阳 娜娜", "欧阳", true},
+		{"mixed script single word", "Li李", "L", false},
+		{"empty input", "张三", "", false},
+		{"whitespace input", "Smith", "  ", false},
+		{"empty guest name", "", "", false},
+		{"surrounding spaces", " Smith ", " smith ", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchSurname(tt.guestName, tt.input); got != tt.want {
+				t.Errorf("matchSurname(%q, %q) = %v, want %v", tt.guestName, tt.input, got, tt.want)
+			}
+		})
+	}
+}
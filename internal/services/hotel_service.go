@@ -0,0 +1,410 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // 内置时区数据，避免运行环境缺少 zoneinfo
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+	"luggage-sys2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+type HotelService struct{}
+
+func NewHotelService() *HotelService {
+	return &HotelService{}
+}
+
+const (
+	minRetrievalCodeLength   = 4
+	maxRetrievalCodeLength   = 12
+	minRetrievalCodeAlphabet = 10 // 字符集至少 10 个字符，避免取件码太容易被猜中
+	maxCheckoutMaxAttempts   = 20
+	maxReminderAfterHours    = 30 * 24
+	maxOverduePolicyDays     = 365
+)
+
+var ErrHotelDisabled = errors.New("hotel is disabled")
+
+// HotelSettingsRequest 酒店设置（字段可选，未传的字段保持不变）
+type HotelSettingsRequest struct {
+	Name                  *string `json:"name"`
+	Timezone              *string `json:"timezone"`
+	RetrievalCodeLength   *int    `json:"retrieval_code_length"`
+	RetrievalCodeAlphabet *string `json:"retrieval_code_alphabet"`
+	RetentionDays         *int    `json:"retention_days"`
+	CheckoutVerification  *string `json:"checkout_verification"`
+	CheckoutMaxAttempts   *int    `json:"checkout_max_attempts"`
+	NotifyGuests          *bool   `json:"notify_guests"`
+	ReminderAfterHours    *int    `json:"reminder_after_hours"`
+	OverdueAfterDays      *int    `json:"overdue_after_days"`
+	AbandonedAfterDays    *int    `json:"abandoned_after_days"`
+}
+
+type CreateHotelRequest struct {
+	Name                  string `json:"name" binding:"required"`
+	Timezone              string `json:"timezone"`
+	RetrievalCodeLength   int    `json:"retrieval_code_length"`
+	RetrievalCodeAlphabet string `json:"retrieval_code_alphabet"`
+	RetentionDays         int    `json:"retention_days"`
+	CheckoutVerification  string `json:"checkout_verification"`
+	CheckoutMaxAttempts   int    `json:"checkout_max_attempts"`
+	NotifyGuests          bool   `json:"notify_guests"`
+	ReminderAfterHours    int    `json:"reminder_after_hours"`
+	OverdueAfterDays      int    `json:"overdue_after_days"`
+	AbandonedAfterDays    int    `json:"abandoned_after_days"`
+	AdminUsername         string `json:"admin_username" binding:"required"` // 酒店首个管理员
+	AdminPassword         string `json:"admin_password" binding:"required"`
+}
+
+type UpdateHotelRequest struct {
+	HotelSettingsRequest
+	IsActive *bool `json:"is_active"`
+}
+
+func validateTimezone(tz string) error {
+	if _, err := time.LoadLocation(tz); err != nil {
+		return fmt.Errorf("invalid timezone '%s'", tz)
+	}
+	return nil
+}
+
+func validateRetrievalCodeLength(length int) error {
+	if length < minRetrievalCodeLength || length > maxRetrievalCodeLength {
+		return fmt.Errorf("retrieval_code_length must be between %d and %d", minRetrievalCodeLength, maxRetrievalCodeLength)
+	}
+	return nil
+}
+
+// validateRetrievalCodeAlphabet 字符集只允许数字和大写字母且不能重复，查询时取件码统一转为大写
+func validateRetrievalCodeAlphabet(alphabet string) error {
+	if len(alphabet) < minRetrievalCodeAlphabet || len(alphabet) > 36 {
+		return fmt.Errorf("retrieval_code_alphabet must contain %d to 36 characters", minRetrievalCodeAlphabet)
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if !(r >= '0' && r <= '9') && !(r >= 'A' && r <= 'Z') {
+			return errors.New("retrieval_code_alphabet may only contain digits and uppercase letters")
+		}
+		if seen[r] {
+			return fmt.Errorf("retrieval_code_alphabet contains duplicate character '%c'", r)
+		}
+		seen[r] = true
+	}
+	return nil
+}
+
+func validateCheckoutVerification(mode string) error {
+	switch mode {
+	case models.CheckoutVerifyNone, models.CheckoutVerifyPhoneLast4, models.CheckoutVerifySurname, models.CheckoutVerifyPIN:
+		return nil
+	default:
+		return fmt.Errorf("invalid checkout_verification '%s', expected none, phone_last4, surname or pin", mode)
+	}
+}
+
+func validateCheckoutMaxAttempts(attempts int) error {
+	if attempts < 1 || attempts > maxCheckoutMaxAttempts {
+		return fmt.Errorf("checkout_max_attempts must be between 1 and %d", maxCheckoutMaxAttempts)
+	}
+	return nil
+}
+
+func validateReminderAfterHours(hours int) error {
+	if hours < 0 || hours > maxReminderAfterHours {
+		return fmt.Errorf("reminder_after_hours must be between 0 and %d", maxReminderAfterHours)
+	}
+	return nil
+}
+
+// validateOverduePolicyDays 校验逾期 / 无人认领天数，field 用于错误信息
+func validateOverduePolicyDays(field string, days int) error {
+	if days < 0 || days > maxOverduePolicyDays {
+		return fmt.Errorf("%s must be between 0 and %d", field, maxOverduePolicyDays)
+	}
+	return nil
+}
+
+func validateRetentionDays(days int) error {
+	if days < 0 {
+		return errors.New("retention_days must not be negative")
+	}
+	return nil
+}
+
+// GetHotel 获取酒店及其设置
+func (s *HotelService) GetHotel(hotelID uint) (*models.Hotel, error) {
+	var hotel models.Hotel
+	if err := database.DB.Where("id = ?", hotelID).First(&hotel).Error; err != nil {
+		return nil, errors.New("hotel not found")
+	}
+	return &hotel, nil
+}
+
+func (s *HotelService) ListHotels() ([]models.Hotel, error) {
+	var hotels []models.Hotel
+	if err := database.DB.Order("id ASC").Find(&hotels).Error; err != nil {
+		return nil, err
+	}
+	return hotels, nil
+}
+
+// CreateHotel 创建酒店及其首个管理员账号（管理员首次登录须修改密码）
+func (s *HotelService) CreateHotel(req CreateHotelRequest) (*models.Hotel, *models.User, error) {
+	if req.Timezone == "" {
+		req.Timezone = models.DefaultHotelTimezone
+	}
+	if req.RetrievalCodeLength == 0 {
+		req.RetrievalCodeLength = models.DefaultRetrievalCodeLength
+	}
+	if req.RetrievalCodeAlphabet == "" {
+		req.RetrievalCodeAlphabet = models.DefaultRetrievalCodeAlphabet
+	}
+	if req.CheckoutVerification == "" {
+		req.CheckoutVerification = models.CheckoutVerifyNone
+	}
+	if req.CheckoutMaxAttempts == 0 {
+		req.CheckoutMaxAttempts = models.DefaultCheckoutMaxAttempts
+	}
+	if err := validateTimezone(req.Timezone); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRetrievalCodeLength(req.RetrievalCodeLength); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRetrievalCodeAlphabet(req.RetrievalCodeAlphabet); err != nil {
+		return nil, nil, err
+	}
+	if err := validateCheckoutVerification(req.CheckoutVerification); err != nil {
+		return nil, nil, err
+	}
+	if err := validateCheckoutMaxAttempts(req.CheckoutMaxAttempts); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRetentionDays(req.RetentionDays); err != nil {
+		return nil, nil, err
+	}
+	if err := validateReminderAfterHours(req.ReminderAfterHours); err != nil {
+		return nil, nil, err
+	}
+	if err := validateOverduePolicyDays("overdue_after_days", req.OverdueAfterDays); err != nil {
+		return nil, nil, err
+	}
+	if err := validateOverduePolicyDays("abandoned_after_days", req.AbandonedAfterDays); err != nil {
+		return nil, nil, err
+	}
+	if err := validatePassword(req.AdminPassword); err != nil {
+		return nil, nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.AdminPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hotel := models.Hotel{
+		Name:                  req.Name,
+		Timezone:              req.Timezone,
+		RetrievalCodeLength:   req.RetrievalCodeLength,
+		RetrievalCodeAlphabet: req.RetrievalCodeAlphabet,
+		RetentionDays:         req.RetentionDays,
+		CheckoutVerification:  req.CheckoutVerification,
+		CheckoutMaxAttempts:   req.CheckoutMaxAttempts,
+		NotifyGuests:          req.NotifyGuests,
+		ReminderAfterHours:    req.ReminderAfterHours,
+		OverdueAfterDays:      req.OverdueAfterDays,
+		AbandonedAfterDays:    req.AbandonedAfterDays,
+		IsActive:              true,
+	}
+	var admin models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.User{}).Where("username = ?", req.AdminUsername).Count(&count)
+		if count > 0 {
+			return errors.New("username already exists")
+		}
+
+		if err := tx.Create(&hotel).Error; err != nil {
+			return errors.New("create hotel failed")
+		}
+
+		admin = models.User{
+			Username:           req.AdminUsername,
+			Password:           hashedPassword,
+			Role:               models.RoleAdmin,
+			HotelID:            hotel.ID,
+			IsActive:           true,
+			MustChangePassword: true,
+		}
+		if err := tx.Create(&admin).Error; err != nil {
+			return errors.New("create hotel admin failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &hotel, &admin, nil
+}
+
+// applySettings 校验并应用设置字段
+func applySettings(hotel *models.Hotel, req HotelSettingsRequest) error {
+	if req.Name != nil {
+		if *req.Name == "" {
+			return errors.New("name is empty")
+		}
+		hotel.Name = *req.Name
+	}
+	if req.Timezone != nil {
+		if err := validateTimezone(*req.Timezone); err != nil {
+			return err
+		}
+		hotel.Timezone = *req.Timezone
+	}
+	if req.RetrievalCodeLength != nil {
+		if err := validateRetrievalCodeLength(*req.RetrievalCodeLength); err != nil {
+			return err
+		}
+		hotel.RetrievalCodeLength = *req.RetrievalCodeLength
+	}
+	if req.RetrievalCodeAlphabet != nil {
+		if err := validateRetrievalCodeAlphabet(*req.RetrievalCodeAlphabet); err != nil {
+			return err
+		}
+		hotel.RetrievalCodeAlphabet = *req.RetrievalCodeAlphabet
+	}
+	if req.RetentionDays != nil {
+		if err := validateRetentionDays(*req.RetentionDays); err != nil {
+			return err
+		}
+		hotel.RetentionDays = *req.RetentionDays
+	}
+	if req.CheckoutVerification != nil {
+		if err := validateCheckoutVerification(*req.CheckoutVerification); err != nil {
+			return err
+		}
+		hotel.CheckoutVerification = *req.CheckoutVerification
+	}
+	if req.CheckoutMaxAttempts != nil {
+		if err := validateCheckoutMaxAttempts(*req.CheckoutMaxAttempts); err != nil {
+			return err
+		}
+		hotel.CheckoutMaxAttempts = *req.CheckoutMaxAttempts
+	}
+	if req.NotifyGuests != nil {
+		hotel.NotifyGuests = *req.NotifyGuests
+	}
+	if req.ReminderAfterHours != nil {
+		if err := validateReminderAfterHours(*req.ReminderAfterHours); err != nil {
+			return err
+		}
+		hotel.ReminderAfterHours = *req.ReminderAfterHours
+	}
+	if req.OverdueAfterDays != nil {
+		if err := validateOverduePolicyDays("overdue_after_days", *req.OverdueAfterDays); err != nil {
+			return err
+		}
+		hotel.OverdueAfterDays = *req.OverdueAfterDays
+	}
+	if req.AbandonedAfterDays != nil {
+		if err := validateOverduePolicyDays("abandoned_after_days", *req.AbandonedAfterDays); err != nil {
+			return err
+		}
+		hotel.AbandonedAfterDays = *req.AbandonedAfterDays
+	}
+	return nil
+}
+
+// UpdateSettings 酒店管理员修改本酒店设置
+func (s *HotelService) UpdateSettings(hotelID uint, req HotelSettingsRequest) (*models.Hotel, error) {
+	hotel, err := s.GetHotel(hotelID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySettings(hotel, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(hotel).Error; err != nil {
+		return nil, errors.New("update hotel settings failed")
+	}
+	return hotel, nil
+}
+
+// UpdateHotel 平台管理员修改酒店（含启用/停用），停用后该酒店所有账号立即下线
+func (s *HotelService) UpdateHotel(id uint, req UpdateHotelRequest) (*models.Hotel, error) {
+	hotel, err := s.GetHotel(id)
+	if err != nil {
+		return nil, errors.New("invalid hotel id")
+	}
+	if err := applySettings(hotel, req.HotelSettingsRequest); err != nil {
+		return nil, err
+	}
+	if req.IsActive != nil {
+		hotel.IsActive = *req.IsActive
+	}
+	if err := database.DB.Save(hotel).Error; err != nil {
+		return nil, errors.New("update hotel failed")
+	}
+
+	if !hotel.IsActive {
+		if err := database.DB.Model(&models.Session{}).
+			Where("hotel_id = ? AND revoked_at IS NULL", hotel.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return nil, errors.New("revoke hotel sessions failed")
+		}
+	}
+	return hotel, nil
+}
+
+// PurgeExpiredLuggage 按各酒店的保留天数软删除已取出（或已处置、移交失物招领）的行李记录，返回删除数量
+func (s *HotelService) PurgeExpiredLuggage() (int64, error) {
+	var hotels []models.Hotel
+	if err := database.DB.Where("retention_days > 0").Find(&hotels).Error; err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, hotel := range hotels {
+		cutoff := time.Now().AddDate(0, 0, -hotel.RetentionDays)
+		result := database.DB.Scopes(database.HotelScope(hotel.ID)).
+			Where("((status = ? AND retrieved_at < ?) OR (status IN ? AND disposed_at < ?))",
+				models.LuggageStatusRetrieved, cutoff,
+				[]string{models.LuggageStatusDisposed, models.LuggageStatusLostAndFound}, cutoff).
+			Delete(&models.Luggage{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+	}
+	return total, nil
+}
+
+// StartRetentionJob 后台定期清理超过保留期的记录（启动后执行一次，之后每天一次）
+func StartRetentionJob() {
+	go func() {
+		for {
+			if n, err := NewHotelService().PurgeExpiredLuggage(); err != nil {
+				log.Printf("Retention job failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Retention job purged %d retrieved luggage records", n)
+			}
+			if n, err := NewLuggageService().PurgeCheckoutIdempotencyKeys(); err != nil {
+				log.Printf("Retention job failed to purge idempotency keys: %v", err)
+			} else if n > 0 {
+				log.Printf("Retention job purged %d checkout idempotency keys", n)
+			}
+			if n, err := NewLoginGuardService().PurgeStaleThrottles(); err != nil {
+				log.Printf("Retention job failed to purge login throttles: %v", err)
+			} else if n > 0 {
+				log.Printf("Retention job purged %d stale login throttles", n)
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
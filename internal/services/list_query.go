@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListQuery 列表接口通用的分页、过滤和排序参数，未设置的字段不过滤
+type ListQuery struct {
+	Cursor    string     // 上一页返回的 next_cursor，为空时从第一页开始
+	Limit     int        // 每页条数，默认 50，最多 500
+	From      *time.Time // 时间范围 [From, To)，按各列表的时间列过滤
+	To        *time.Time
+	GuestName string // 客人姓名，包含匹配
+	Staff     string // 操作人 / 经办人
+	LuggageID uint
+	Sort      string // 排序字段，默认为各列表的时间列
+	Order     string // asc / desc，默认 desc
+}
+
+// ListPage 一页结果：Total 为满足过滤条件的总数（不受分页影响），NextCursor 为空表示没有下一页
+type ListPage[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+}
+
+// listSpec 描述某个列表可用的过滤列和排序列
+type listSpec struct {
+	timeColumn      string            // 日期范围过滤和默认排序的列
+	defaultSort     string            // 默认排序参数，为空时为 timeColumn
+	guestColumn     string            // 为空时通过 luggage_id 关联行李表的 guest_name
+	staffColumn     string            // 为空时通过 luggage_id 关联行李表的 staff_name
+	luggageIDColumn string            // 默认 luggage_id
+	sortColumns     map[string]string // 排序参数 -> 列名，列不能为空值
+}
+
+// listCursor 游标记录上一页最后一行的排序值和 id，id 用于排序值相同时继续翻页
+type listCursor struct {
+	Sort  string     `json:"k"`
+	Order string     `json:"o"`
+	Time  *time.Time `json:"t,omitempty"`
+	Str   *string    `json:"s,omitempty"`
+	ID    uint       `json:"id"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID == 0 || (c.Time == nil && c.Str == nil) {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// escapeLike 转义 LIKE 通配符（MySQL 默认以反斜杠转义）
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// paginate 按 spec 对 db（已限定酒店）应用过滤条件，统计总数，再按排序列 + id 做游标分页。
+// sortKey 返回某行的排序值（time.Time 或 string）和 id，用于生成下一页游标
+func paginate[T any](db *gorm.DB, hotelID uint, q ListQuery, spec listSpec, sortKey func(T, string) (interface{}, uint)) (*ListPage[T], error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	sortName := q.Sort
+	if sortName == "" {
+		sortName = spec.defaultSort
+	}
+	if sortName == "" {
+		sortName = spec.timeColumn
+	}
+	column, ok := spec.sortColumns[sortName]
+	if !ok {
+		names := make([]string, 0, len(spec.sortColumns))
+		for name := range spec.sortColumns {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("invalid sort '%s', expected one of %s", sortName, strings.Join(names, ", "))
+	}
+	order := strings.ToLower(q.Order)
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("invalid order '%s', expected asc or desc", q.Order)
+	}
+
+	// 过滤条件
+	if q.From != nil {
+		db = db.Where(spec.timeColumn+" >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where(spec.timeColumn+" < ?", *q.To)
+	}
+	if q.GuestName != "" {
+		pattern := "%" + escapeLike(q.GuestName) + "%"
+		if spec.guestColumn != "" {
+			db = db.Where(spec.guestColumn+" LIKE ?", pattern)
+		} else {
+			db = db.Where("luggage_id IN (SELECT id FROM luggages WHERE hotel_id = ? AND guest_name LIKE ?)", hotelID, pattern)
+		}
+	}
+	if q.Staff != "" {
+		if spec.staffColumn != "" {
+			db = db.Where(spec.staffColumn+" = ?", q.Staff)
+		} else {
+			db = db.Where("luggage_id IN (SELECT id FROM luggages WHERE hotel_id = ? AND staff_name = ?)", hotelID, q.Staff)
+		}
+	}
+	if q.LuggageID != 0 {
+		luggageIDColumn := spec.luggageIDColumn
+		if luggageIDColumn == "" {
+			luggageIDColumn = "luggage_id"
+		}
+		db = db.Where(luggageIDColumn+" = ?", q.LuggageID)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// 游标：取排序值在上一页最后一行之后的记录
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != sortName || cursor.Order != order {
+			return nil, fmt.Errorf("%w: cursor was issued for sort=%s order=%s", ErrInvalidCursor, cursor.Sort, cursor.Order)
+		}
+		var value interface{}
+		if cursor.Time != nil {
+			value = *cursor.Time
+		} else {
+			value = *cursor.Str
+		}
+		op := "<"
+		if order == "asc" {
+			op = ">"
+		}
+		db = db.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, op, column, op), value, value, cursor.ID)
+	}
+
+	var items []T
+	if err := db.Order(column + " " + order).Order("id " + order).Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	page := &ListPage[T]{Items: items, Total: total}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		value, id := sortKey(page.Items[limit-1], sortName)
+		next := listCursor{Sort: sortName, Order: order, ID: id}
+		switch v := value.(type) {
+		case time.Time:
+			next.Time = &v
+		case string:
+			next.Str = &v
+		}
+		page.NextCursor = encodeCursor(next)
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page, nil
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"encoding/json"
+
 	"luggage-sys2/internal/database"
 	"luggage-sys2/internal/models"
 )
@@ -11,26 +13,136 @@ func NewLogService() *LogService {
 	return &LogService{}
 }
 
-func (s *LogService) GetStoredLogs(hotelID uint) ([]models.StoredLog, error) {
-	var logs []models.StoredLog
-	if err := database.DB.Where("hotel_id = ?", hotelID).Order("stored_at DESC").Find(&logs).Error; err != nil {
-		return nil, err
+// 各日志列表是审计事件按动作过滤后的视图，保留原有的响应字段和排序参数名；
+// 客人姓名按事件发生时的客人姓名过滤，staff 按操作人过滤
+var (
+	storedLogSpec = listSpec{
+		timeColumn:      "created_at",
+		defaultSort:     "stored_at",
+		guestColumn:     "entity_name",
+		staffColumn:     "actor",
+		luggageIDColumn: "entity_id",
+		sortColumns:     map[string]string{"stored_at": "created_at", "guest_name": "entity_name"},
 	}
-	return logs, nil
-}
+	updatedLogSpec = listSpec{
+		timeColumn:      "created_at",
+		defaultSort:     "updated_at",
+		guestColumn:     "entity_name",
+		staffColumn:     "actor",
+		luggageIDColumn: "entity_id",
+		sortColumns:     map[string]string{"updated_at": "created_at", "updated_by": "actor"},
+	}
+	retrievedLogSpec = listSpec{
+		timeColumn:      "created_at",
+		defaultSort:     "retrieved_at",
+		guestColumn:     "entity_name",
+		staffColumn:     "actor",
+		luggageIDColumn: "entity_id",
+		sortColumns:     map[string]string{"retrieved_at": "created_at", "guest_name": "entity_name", "retrieved_by": "actor"},
+	}
+	movedLogSpec = listSpec{
+		timeColumn:      "created_at",
+		defaultSort:     "moved_at",
+		guestColumn:     "entity_name",
+		staffColumn:     "actor",
+		luggageIDColumn: "entity_id",
+		sortColumns:     map[string]string{"moved_at": "created_at", "guest_name": "entity_name", "moved_by": "actor"},
+	}
+)
 
-func (s *LogService) GetUpdatedLogs(hotelID uint) ([]models.UpdatedLog, error) {
-	var logs []models.UpdatedLog
-	if err := database.DB.Where("hotel_id = ?", hotelID).Order("updated_at DESC").Find(&logs).Error; err != nil {
+// updatedLogActions 修改记录包含的动作：信息修改、逾期状态变更和处置
+var updatedLogActions = []string{models.AuditLuggageUpdated, models.AuditLuggageStatusChanged, models.AuditLuggageDisposed}
+
+// auditLogView 查询本酒店指定动作的行李审计事件并转换为日志记录
+func auditLogView[T any](hotelID uint, actions []string, q ListQuery, spec listSpec, convert func(models.AuditEvent) T) (*ListPage[T], error) {
+	query := database.DB.Model(&models.AuditEvent{}).
+		Where("hotel_id = ? AND entity_type = ? AND action IN ?", hotelID, models.AuditEntityLuggage, actions)
+	page, err := paginate(query, hotelID, q, spec, func(e models.AuditEvent, sort string) (interface{}, uint) {
+		switch spec.sortColumns[sort] {
+		case "entity_name":
+			return e.EntityName, e.ID
+		case "actor":
+			return e.Actor, e.ID
+		}
+		return e.CreatedAt, e.ID
+	})
+	if err != nil {
 		return nil, err
 	}
-	return logs, nil
-}
 
-func (s *LogService) GetRetrievedLogs(hotelID uint) ([]models.RetrievedLog, error) {
-	var logs []models.RetrievedLog
-	if err := database.DB.Where("hotel_id = ?", hotelID).Order("retrieved_at DESC").Find(&logs).Error; err != nil {
-		return nil, err
+	items := make([]T, 0, len(page.Items))
+	for _, event := range page.Items {
+		items = append(items, convert(event))
 	}
-	return logs, nil
+	return &ListPage[T]{Items: items, Total: page.Total, NextCursor: page.NextCursor}, nil
+}
+
+func (s *LogService) GetStoredLogs(hotelID uint, q ListQuery) (*ListPage[models.StoredLog], error) {
+	return auditLogView(hotelID, []string{models.AuditLuggageStored}, q, storedLogSpec, func(e models.AuditEvent) models.StoredLog {
+		status, _ := e.Changes.NewValue("status").(string)
+		return models.StoredLog{
+			ID:        e.ID,
+			HotelID:   e.HotelID,
+			LuggageID: e.EntityID,
+			GuestName: e.EntityName,
+			Status:    status,
+			StoredAt:  e.CreatedAt,
+		}
+	})
+}
+
+// GetUpdatedLogs 修改记录，old_data / new_data 只包含变化的字段，处置说明在 new_data.note 中
+func (s *LogService) GetUpdatedLogs(hotelID uint, q ListQuery) (*ListPage[models.UpdatedLog], error) {
+	return auditLogView(hotelID, updatedLogActions, q, updatedLogSpec, func(e models.AuditEvent) models.UpdatedLog {
+		oldData := make(map[string]interface{}, len(e.Changes))
+		newData := make(map[string]interface{}, len(e.Changes)+1)
+		for field, change := range e.Changes {
+			oldData[field] = change.Old
+			newData[field] = change.New
+		}
+		if e.Note != "" {
+			newData["note"] = e.Note
+		}
+		oldJSON, _ := json.Marshal(oldData)
+		newJSON, _ := json.Marshal(newData)
+		return models.UpdatedLog{
+			ID:        e.ID,
+			HotelID:   e.HotelID,
+			LuggageID: e.EntityID,
+			UpdatedBy: e.Actor,
+			OldData:   string(oldJSON),
+			NewData:   string(newJSON),
+			UpdatedAt: e.CreatedAt,
+		}
+	})
+}
+
+func (s *LogService) GetRetrievedLogs(hotelID uint, q ListQuery) (*ListPage[models.RetrievedLog], error) {
+	return auditLogView(hotelID, []string{models.AuditLuggageRetrieved}, q, retrievedLogSpec, func(e models.AuditEvent) models.RetrievedLog {
+		return models.RetrievedLog{
+			ID:          e.ID,
+			HotelID:     e.HotelID,
+			LuggageID:   e.EntityID,
+			GuestName:   e.EntityName,
+			RetrievedBy: e.Actor,
+			RetrievedAt: e.CreatedAt,
+		}
+	})
+}
+
+func (s *LogService) GetMovedLogs(hotelID uint, q ListQuery) (*ListPage[models.MovedLog], error) {
+	return auditLogView(hotelID, []string{models.AuditLuggageMoved}, q, movedLogSpec, func(e models.AuditEvent) models.MovedLog {
+		from, to := e.Changes.StoreroomMove()
+		return models.MovedLog{
+			ID:              e.ID,
+			HotelID:         e.HotelID,
+			LuggageID:       e.EntityID,
+			GuestName:       e.EntityName,
+			FromStoreroomID: from,
+			ToStoreroomID:   to,
+			MovedBy:         e.Actor,
+			Note:            e.Note,
+			MovedAt:         e.CreatedAt,
+		}
+	})
 }
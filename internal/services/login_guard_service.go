@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginGuardService 登录防暴力破解：按用户名和 IP 统计连续失败次数，
+// 用户名维度按指数退避限制重试间隔，任一维度达到阈值后临时锁定。
+// 检查和计数在同一事务中完成（Acquire），被限制的请求不写入登录记录和审计事件
+type LoginGuardService struct{}
+
+func NewLoginGuardService() *LoginGuardService {
+	return &LoginGuardService{}
+}
+
+const (
+	loginBackoffBase = time.Second
+	loginBackoffMax  = time.Minute
+)
+
+// 登录审计原因
+const (
+	LoginReasonBadCredentials  = "bad_credentials"
+	LoginReasonAccountDisabled = "account_disabled"
+	LoginReasonHotelDisabled   = "hotel_disabled"
+)
+
+// LoginBlockedError 登录被限流或锁定
+type LoginBlockedError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	seconds := int(e.RetryAfter.Round(time.Second).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	if e.Locked {
+		return fmt.Sprintf("too many failed attempts, account locked, retry after %d seconds", seconds)
+	}
+	return fmt.Sprintf("too many failed attempts, retry after %d seconds", seconds)
+}
+
+// LockoutStatus 管理员查看的用户锁定状态
+type LockoutStatus struct {
+	UserID       uint       `json:"user_id"`
+	Username     string     `json:"username"`
+	FailedCount  int        `json:"failed_count"`
+	LastFailedAt *time.Time `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	Locked       bool       `json:"locked"`
+}
+
+func userThrottleKey(username string) string {
+	return "user:" + username
+}
+
+func ipThrottleKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// unknownThrottleKey 用户名不存在的失败登录按 IP 聚合计数
+func unknownThrottleKey(clientIP string) string {
+	return "unknown:" + clientIP
+}
+
+// backoffFor 第 n 次连续失败后需要等待的时间：1s、2s、4s ... 最长 1 分钟
+func backoffFor(failedCount int) time.Duration {
+	if failedCount <= 0 {
+		return 0
+	}
+	d := loginBackoffBase << uint(failedCount-1)
+	if d <= 0 || d > loginBackoffMax {
+		return loginBackoffMax
+	}
+	return d
+}
+
+// isStale 超过锁定时长没有新的失败时，计数视为过期
+func isStale(throttle *models.LoginThrottle, now time.Time) bool {
+	return now.Sub(throttle.LastFailedAt) > config.LoginLockoutDuration
+}
+
+// lockThrottle 在事务内锁定计数行（不存在时创建）
+func lockThrottle(tx *gorm.DB, key string, now time.Time) (*models.LoginThrottle, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Key: key, LastFailedAt: now}).Error; err != nil {
+		return nil, err
+	}
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("throttle_key = ?", key).
+		First(&throttle).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// blockedBy IP 或用户名被锁定、用户名处于退避期时返回 LoginBlockedError；用户名不存在时 userThrottle 为 nil，只检查 IP
+func blockedBy(ipThrottle, userThrottle *models.LoginThrottle, now time.Time) error {
+	for _, t := range []*models.LoginThrottle{ipThrottle, userThrottle} {
+		if t != nil && t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+			return &LoginBlockedError{Locked: true, RetryAfter: t.LockedUntil.Sub(now)}
+		}
+	}
+	if userThrottle == nil || isStale(userThrottle, now) {
+		return nil
+	}
+	if next := userThrottle.LastFailedAt.Add(backoffFor(userThrottle.FailedCount)); now.Before(next) {
+		return &LoginBlockedError{RetryAfter: next.Sub(now)}
+	}
+	return nil
+}
+
+// countAttempt 把本次尝试计为失败（过期的计数先清零），达到阈值时设置锁定截止时间
+func countAttempt(tx *gorm.DB, throttle *models.LoginThrottle, maxFailures int, now time.Time) error {
+	if isStale(throttle, now) {
+		throttle.FailedCount = 0
+	}
+	throttle.FailedCount++
+	updates := map[string]interface{}{
+		"failed_count":   throttle.FailedCount,
+		"last_failed_at": now,
+		"locked_until":   nil,
+	}
+	if throttle.FailedCount >= maxFailures {
+		updates["locked_until"] = now.Add(config.LoginLockoutDuration)
+	}
+	return tx.Model(&models.LoginThrottle{}).Where("id = ?", throttle.ID).Updates(updates).Error
+}
+
+// Acquire 登录前检查并占用一次尝试：在同一事务中按 IP、用户名的顺序锁定计数行，被锁定或处于退避期时返回 *LoginBlockedError；
+// 否则先把本次尝试计为失败，密码正确后由 Release 退回。并发的猜测在行锁上排队，后到的会被前一次尝试的退避挡住。
+// 只为存在的用户名创建 user: 计数行，不存在的用户名只按 IP 计数，避免任意用户名撑大计数表
+func (s *LoginGuardService) Acquire(username, clientIP string) error {
+	now := time.Now()
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		ipThrottle, err := lockThrottle(tx, ipThrottleKey(clientIP), now)
+		if err != nil {
+			return err
+		}
+		var exists int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&exists).Error; err != nil {
+			return err
+		}
+		var userThrottle *models.LoginThrottle
+		if exists > 0 {
+			if userThrottle, err = lockThrottle(tx, userThrottleKey(username), now); err != nil {
+				return err
+			}
+		}
+		if err := blockedBy(ipThrottle, userThrottle, now); err != nil {
+			return err
+		}
+		if err := countAttempt(tx, ipThrottle, config.LoginIPMaxFailures, now); err != nil {
+			return err
+		}
+		if userThrottle == nil {
+			return nil
+		}
+		return countAttempt(tx, userThrottle, config.LoginMaxFailures, now)
+	})
+}
+
+// Release 密码正确：清除该用户名的失败计数，退回本次尝试计入的 IP 失败次数（IP 的其他失败保留，按时间自然过期）
+func (s *LoginGuardService) Release(username, clientIP string) {
+	ipKey := ipThrottleKey(clientIP)
+	_ = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("throttle_key = ?", userThrottleKey(username)).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.LoginThrottle{}).
+			Where("throttle_key = ? AND failed_count > 0", ipKey).
+			Update("failed_count", gorm.Expr("failed_count - 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.LoginThrottle{}).
+			Where("throttle_key = ? AND failed_count < ?", ipKey, config.LoginIPMaxFailures).
+			Update("locked_until", nil).Error
+	})
+}
+
+// RecordFailure 记录一次失败登录（登录记录 + 审计），actor.Username 为尝试登录的用户名；失败次数已在 Acquire 中计入。
+// 用户名不存在时按 IP 聚合，同一 IP 在锁定时长内只记录第一次，避免未登录的请求刷写审计日志
+func (s *LoginGuardService) RecordFailure(user *models.User, actor Actor, userAgent, reason string) {
+	if user == nil && !s.firstUnknownFailure(actor.ClientIP) {
+		return
+	}
+	s.recordAttempt(user, actor, userAgent, false, reason)
+}
+
+// firstUnknownFailure 为 IP 的用户名不存在失败计数，返回是否为本时段内的第一次
+func (s *LoginGuardService) firstUnknownFailure(clientIP string) bool {
+	now := time.Now()
+	first := false
+	_ = database.DB.Transaction(func(tx *gorm.DB) error {
+		throttle, err := lockThrottle(tx, unknownThrottleKey(clientIP), now)
+		if err != nil {
+			return err
+		}
+		if isStale(throttle, now) {
+			throttle.FailedCount = 0
+		}
+		first = throttle.FailedCount == 0
+		return tx.Model(&models.LoginThrottle{}).Where("id = ?", throttle.ID).
+			Updates(map[string]interface{}{"failed_count": throttle.FailedCount + 1, "last_failed_at": now}).Error
+	})
+	return first
+}
+
+// RecordSuccess 登录成功：记录登录记录和审计（失败计数已由 Release 清除）
+func (s *LoginGuardService) RecordSuccess(user *models.User, actor Actor, userAgent string) {
+	actor.Username = user.Username
+	s.recordAttempt(user, actor, userAgent, true, "")
+}
+
+// recordAttempt 在同一事务中写入登录记录和审计事件
+func (s *LoginGuardService) recordAttempt(user *models.User, actor Actor, userAgent string, success bool, reason string) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	username := truncate(actor.Username, 64)
+	attempt := models.LoginAttempt{
+		Username:  username,
+		ClientIP:  actor.ClientIP,
+		UserAgent: userAgent,
+		Success:   success,
+		Reason:    reason,
+	}
+	record := auditRecord{
+		Action:     models.AuditLoginFailed,
+		EntityType: models.AuditEntityUser,
+		EntityName: username,
+		Note:       reason,
+	}
+	if success {
+		record.Action = models.AuditLoginSucceeded
+	}
+	if user != nil {
+		attempt.HotelID = user.HotelID
+		attempt.UserID = user.ID
+		record.EntityID = user.ID
+	}
+	_ = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, attempt.HotelID, actor, record)
+	})
+}
+
+// PurgeStaleThrottles 清理已过期的计数行：超过锁定时长没有新的失败且未处于锁定中，与不存在时的效果相同
+func (s *LoginGuardService) PurgeStaleThrottles() (int64, error) {
+	now := time.Now()
+	result := database.DB.
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-config.LoginLockoutDuration), now).
+		Delete(&models.LoginThrottle{})
+	return result.RowsAffected, result.Error
+}
+
+// ListLockouts 列出本酒店有失败计数或被锁定的用户
+func (s *LoginGuardService) ListLockouts(hotelID uint) ([]LockoutStatus, error) {
+	var users []models.User
+	if err := database.DB.Where("hotel_id = ?", hotelID).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return []LockoutStatus{}, nil
+	}
+
+	keys := make([]string, 0, len(users))
+	userByKey := make(map[string]models.User, len(users))
+	for _, u := range users {
+		key := userThrottleKey(u.Username)
+		keys = append(keys, key)
+		userByKey[key] = u
+	}
+
+	var throttles []models.LoginThrottle
+	if err := database.DB.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]LockoutStatus, 0, len(throttles))
+	for i := range throttles {
+		t := &throttles[i]
+		if isStale(t, now) && (t.LockedUntil == nil || now.After(*t.LockedUntil)) {
+			continue
+		}
+		u := userByKey[t.Key]
+		items = append(items, LockoutStatus{
+			UserID:       u.ID,
+			Username:     u.Username,
+			FailedCount:  t.FailedCount,
+			LastFailedAt: &t.LastFailedAt,
+			LockedUntil:  t.LockedUntil,
+			Locked:       t.LockedUntil != nil && now.Before(*t.LockedUntil),
+		})
+	}
+	return items, nil
+}
+
+// Unlock 管理员解除用户锁定
+func (s *LoginGuardService) Unlock(userID uint, hotelID uint) error {
+	var user models.User
+	if err := database.DB.Where("id = ? AND hotel_id = ?", userID, hotelID).First(&user).Error; err != nil {
+		return errors.New("invalid user id")
+	}
+	if err := database.DB.Where("throttle_key = ?", userThrottleKey(user.Username)).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return errors.New("unlock user failed")
+	}
+	return nil
+}
+
+// ListFailedAttempts 本酒店账号的失败登录记录，可按用户名 / IP 过滤，最多返回 limit 条
+func (s *LoginGuardService) ListFailedAttempts(hotelID uint, username, clientIP string, limit int) ([]models.LoginAttempt, error) {
+	query := database.DB.Where("hotel_id = ? AND success = ?", hotelID, false)
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if clientIP != "" {
+		query = query.Where("client_ip = ?", clientIP)
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
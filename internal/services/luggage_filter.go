@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// LuggageFilter 行李列表通用过滤条件，未设置的字段不过滤
+type LuggageFilter struct {
+	RoomNumber    string
+	ReservationID string
+	ExpectedFrom  *time.Time // 预计取件时间范围 [ExpectedFrom, ExpectedTo)
+	ExpectedTo    *time.Time
+}
+
+// Scope 把过滤条件加到行李查询上：database.DB.Scopes(database.HotelScope(hotelID), filter.Scope)
+func (f LuggageFilter) Scope(db *gorm.DB) *gorm.DB {
+	if f.RoomNumber != "" {
+		db = db.Where("room_number = ?", f.RoomNumber)
+	}
+	if f.ReservationID != "" {
+		db = db.Where("reservation_id = ?", f.ReservationID)
+	}
+	if f.ExpectedFrom != nil {
+		db = db.Where("expected_pickup_at >= ?", *f.ExpectedFrom)
+	}
+	if f.ExpectedTo != nil {
+		db = db.Where("expected_pickup_at < ?", *f.ExpectedTo)
+	}
+	return db
+}
+
+// ListDueLuggage 某天（酒店时区，默认今天）预计取件、仍在寄存室的行李，按预计取件时间排序，
+// 便于前台提前备好行李；返回实际查询的日期
+func (s *LuggageService) ListDueLuggage(hotelID uint, date string, filter LuggageFilter) ([]models.Luggage, string, error) {
+	hotel, err := NewHotelService().GetHotel(hotelID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	loc := hotel.Location()
+	var dayStart time.Time
+	if date == "" {
+		now := time.Now().In(loc)
+		dayStart = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	} else {
+		dayStart, err = time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return nil, "", errors.New("invalid date, expected YYYY-MM-DD")
+		}
+	}
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var luggages []models.Luggage
+	if err := database.DB.Scopes(database.HotelScope(hotelID), filter.Scope).
+		Where("status IN ? AND expected_pickup_at >= ? AND expected_pickup_at < ?", models.InStorageStatuses, dayStart, dayEnd).
+		Order("expected_pickup_at ASC, room_number ASC, id ASC").
+		Find(&luggages).Error; err != nil {
+		return nil, "", err
+	}
+	return luggages, dayStart.Format("2006-01-02"), nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AppendLuggageRequest 向已有取件码追加行李
+type AppendLuggageRequest struct {
+	Items          []LuggageItem `json:"items" binding:"required"`
+	AutoAssignSlot bool          `json:"auto_assign_slot"` // 未指定 slot_id 的行李自动分配格位
+}
+
+// normalizeItems 设置数量和尺寸默认值（数量默认为 1，尺寸默认为 small）并校验尺寸分类
+func normalizeItems(items []LuggageItem) error {
+	for i := range items {
+		if items[i].Quantity <= 0 {
+			items[i].Quantity = 1
+		}
+		if items[i].SizeClass == "" {
+			items[i].SizeClass = models.SizeSmall
+		}
+		if err := validateSizeClass(items[i].SizeClass); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeItems 在事务内写入一批行李：锁定涉及的寄存室后检查容量并分配格位，每件行李复制 base 中的客人信息和取件码，
+// 全部写入后再逐件写入寄存审计事件。寄存和追加行李共用
+func (s *LuggageService) storeItems(tx *gorm.DB, hotelID uint, base models.Luggage, items []LuggageItem, autoAssignSlot bool, actor Actor) ([]models.Luggage, error) {
+	storeroomIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		storeroomIDs = append(storeroomIDs, item.StoreroomID)
+	}
+	storerooms, err := s.lockStorerooms(tx, hotelID, storeroomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 寄存室行已锁定，统计结果在本事务结束前不会被其他寄存改变
+	usage, err := storedUnits(tx, hotelID, storeroomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := newSlotAllocator(tx, hotelID)
+	created := make([]models.Luggage, 0, len(items))
+	records := make([]auditRecord, 0, len(items))
+	for _, item := range items {
+		storeroom := storerooms[item.StoreroomID]
+
+		// 检查容量：按件数（quantity 之和）计算，设置了分类容量时同时检查分类容量
+		if usage[item.StoreroomID] == nil {
+			usage[item.StoreroomID] = make(map[string]int)
+		}
+		used := usage[item.StoreroomID]
+		total := 0
+		for _, units := range used {
+			total += units
+		}
+		if total+item.Quantity > storeroom.Capacity {
+			return nil, ErrStoreroomFull
+		}
+		if limit := storeroom.SizeCapacity(item.SizeClass); limit != nil && used[item.SizeClass]+item.Quantity > *limit {
+			return nil, fmt.Errorf("%w: no free %s slots", ErrStoreroomFull, item.SizeClass)
+		}
+		used[item.SizeClass] += item.Quantity
+
+		slot, err := slots.assign(item.StoreroomID, item.SlotID, item.Quantity, autoAssignSlot)
+		if err != nil {
+			return nil, err
+		}
+
+		// 处理图片：优先 photo_urls，否则 photo_url -> photo_urls=[photo_url]
+		photoURLs := item.PhotoURLs
+		if len(photoURLs) == 0 && item.PhotoURL != "" {
+			photoURLs = []string{item.PhotoURL}
+		}
+		photoURL := item.PhotoURL
+		if photoURL == "" && len(photoURLs) > 0 {
+			photoURL = photoURLs[0]
+		}
+
+		// 创建行李记录
+		luggage := base
+		luggage.ID = 0
+		luggage.HotelID = hotelID
+		luggage.Description = item.Description
+		luggage.Quantity = item.Quantity
+		luggage.SizeClass = item.SizeClass
+		luggage.SpecialNotes = item.SpecialNotes
+		luggage.PhotoURLs = models.StringSlice(photoURLs)
+		luggage.PhotoURL = photoURL
+		luggage.StoreroomID = item.StoreroomID
+		if slot != nil {
+			luggage.SlotID = &slot.ID
+			luggage.SlotCode = slot.Code
+		}
+		luggage.Status = models.LuggageStatusStored
+		if err := tx.Create(&luggage).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, luggage)
+		records = append(records, luggageAudit(models.AuditLuggageStored, nil, luggage, ""))
+	}
+	if err := recordAudit(tx, hotelID, actor, records...); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// AppendLuggageItems 向仍有在存行李的取件码追加行李，客人继续使用同一张凭条。
+// 新行李沿用该取件码下已有行李的客人信息，经办人为当前用户；容量检查与寄存相同
+func (s *LuggageService) AppendLuggageItems(code string, req AppendLuggageRequest, hotelID uint, actor Actor) ([]models.Luggage, error) {
+	code = normalizeRetrievalCode(code)
+	if len(req.Items) == 0 {
+		return nil, errors.New("items is empty")
+	}
+	items := req.Items
+	if err := normalizeItems(items); err != nil {
+		return nil, err
+	}
+
+	var created []models.Luggage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定取件码下的在存行李，避免与取件并发时取件码被释放
+		var existing []models.Luggage
+		if err := tx.Scopes(database.HotelScope(hotelID)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("retrieval_code = ? AND status IN ?", code, models.InStorageStatuses).
+			Order("id ASC").
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			hotel, _ := NewHotelService().GetHotel(hotelID)
+			return retrievalCodeNotFound(code, hotel, ErrNoStoredLuggageForCode)
+		}
+
+		first := existing[0]
+		var err error
+		created, err = s.storeItems(tx, hotelID, models.Luggage{
+			GuestName:        first.GuestName,
+			StaffName:        actor.Username,
+			ContactPhone:     first.ContactPhone,
+			ContactEmail:     first.ContactEmail,
+			RoomNumber:       first.RoomNumber,
+			ReservationID:    first.ReservationID,
+			RetrievalCode:    code,
+			ExpectedPickupAt: first.ExpectedPickupAt,
+		}, items, req.AutoAssignSlot, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
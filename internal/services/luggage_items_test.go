@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+)
+
+// TestCreateLuggageConcurrentCapacity 多个 goroutine 同时向同一寄存室寄存：成功数恰好等于容量，已存件数不超过容量。
+// 依赖 MySQL 的行锁，需要通过 TEST_DB_DSN 指定一个测试库（表会自动创建），例如：
+//
+//	TEST_DB_DSN='root:123456@tcp(127.0.0.1:3306)/hotel_luggage_test?charset=utf8mb4&parseTime=True&loc=Local' \
+//		go test ./internal/services -run TestCreateLuggageConcurrentCapacity
+func TestCreateLuggageConcurrentCapacity(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set, skipping MySQL concurrency test")
+	}
+	config.Init()
+	config.DBDSN = dsn
+	database.Init()
+
+	const (
+		capacity   = 5
+		goroutines = 40
+	)
+
+	hotel := models.Hotel{
+		Name:                  "concurrency test",
+		Timezone:              models.DefaultHotelTimezone,
+		RetrievalCodeLength:   models.DefaultRetrievalCodeLength,
+		RetrievalCodeAlphabet: models.DefaultRetrievalCodeAlphabet,
+		CheckoutVerification:  models.CheckoutVerifyNone,
+		CheckoutMaxAttempts:   models.DefaultCheckoutMaxAttempts,
+		IsActive:              true,
+	}
+	if err := database.DB.Create(&hotel).Error; err != nil {
+		t.Fatalf("create hotel: %v", err)
+	}
+	actor := Actor{Username: "concurrency_test"}
+	storeroom, err := NewStoreroomService().CreateStoreroom(CreateStoreroomRequest{
+		Name:     "concurrency test",
+		Capacity: capacity,
+		IsActive: true,
+	}, hotel.ID, actor)
+	if err != nil {
+		t.Fatalf("create storeroom: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		full      int
+		start     = make(chan struct{})
+	)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, _, _, err := NewLuggageService().CreateLuggage(CreateLuggageRequest{
+				GuestName:   fmt.Sprintf("guest %d", i),
+				StaffName:   actor.Username,
+				StoreroomID: storeroom.ID,
+				Quantity:    1,
+			}, hotel.ID, actor)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, ErrStoreroomFull):
+				full++
+			default:
+				t.Errorf("deposit %d: unexpected error: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != capacity {
+		t.Errorf("succeeded deposits = %d, want %d", succeeded, capacity)
+	}
+	if full != goroutines-capacity {
+		t.Errorf("rejected as full = %d, want %d", full, goroutines-capacity)
+	}
+
+	usage, err := storedUnits(database.DB, hotel.ID, []uint{storeroom.ID})
+	if err != nil {
+		t.Fatalf("stored units: %v", err)
+	}
+	stored := 0
+	for _, units := range usage[storeroom.ID] {
+		stored += units
+	}
+	if stored > capacity {
+		t.Errorf("stored units = %d, exceeds capacity %d", stored, capacity)
+	}
+	if stored != succeeded {
+		t.Errorf("stored units = %d, want %d (one per successful deposit)", stored, succeeded)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// overdueJobInterval 逾期检查间隔
+const overdueJobInterval = time.Hour
+
+// systemActor 后台任务写入审计事件时使用的操作人
+const systemActor = "system"
+
+var ErrLuggageNotOverdue = errors.New("only overdue or abandoned luggage can be disposed or transferred")
+
+// DisposeLuggageRequest 处置逾期行李
+type DisposeLuggageRequest struct {
+	Note string `json:"note"` // 处置说明，如失物招领登记号
+}
+
+// FlagOverdueLuggage 按各酒店策略标记逾期和无人认领的行李，返回标记数量：
+// 超过预计取件时间、或未填预计取件时间且存放超过 overdue_after_days 天的在存行李标记为 overdue；
+// 逾期超过 abandoned_after_days 天的标记为 abandoned。状态变更写入审计事件
+func (s *LuggageService) FlagOverdueLuggage() (overdue int, abandoned int, err error) {
+	var hotels []models.Hotel
+	if err := database.DB.Where("is_active = ?", true).Find(&hotels).Error; err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	for _, hotel := range hotels {
+		query := database.DB.Scopes(database.HotelScope(hotel.ID)).
+			Where("status = ?", models.LuggageStatusStored)
+		if hotel.OverdueAfterDays > 0 {
+			query = query.Where("(expected_pickup_at < ? OR (expected_pickup_at IS NULL AND stored_at < ?))",
+				now, now.AddDate(0, 0, -hotel.OverdueAfterDays))
+		} else {
+			query = query.Where("expected_pickup_at < ?", now)
+		}
+		var luggages []models.Luggage
+		if err := query.Find(&luggages).Error; err != nil {
+			return overdue, abandoned, err
+		}
+		n, err := s.transitionStatus(hotel.ID, luggages, models.LuggageStatusStored, models.LuggageStatusOverdue, now)
+		if err != nil {
+			return overdue, abandoned, err
+		}
+		overdue += n
+
+		if hotel.AbandonedAfterDays <= 0 {
+			continue
+		}
+		luggages = nil
+		if err := database.DB.Scopes(database.HotelScope(hotel.ID)).
+			Where("status = ? AND overdue_at < ?", models.LuggageStatusOverdue, now.AddDate(0, 0, -hotel.AbandonedAfterDays)).
+			Find(&luggages).Error; err != nil {
+			return overdue, abandoned, err
+		}
+		n, err = s.transitionStatus(hotel.ID, luggages, models.LuggageStatusOverdue, models.LuggageStatusAbandoned, now)
+		if err != nil {
+			return overdue, abandoned, err
+		}
+		abandoned += n
+	}
+	return overdue, abandoned, nil
+}
+
+// transitionStatus 把行李从 from 状态改为 to 状态（按原状态条件更新，期间被取走的行李不受影响），并写入审计事件
+func (s *LuggageService) transitionStatus(hotelID uint, luggages []models.Luggage, from, to string, now time.Time) (int, error) {
+	changed := 0
+	for _, luggage := range luggages {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			updates := map[string]interface{}{"status": to}
+			if to == models.LuggageStatusOverdue {
+				updates["overdue_at"] = now
+			}
+			result := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+				Where("id = ? AND status = ?", luggage.ID, from).
+				Updates(updates)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			old := luggage
+			luggage.Status = to
+			if to == models.LuggageStatusOverdue {
+				luggage.OverdueAt = &now
+			}
+			if err := recordAudit(tx, hotelID, systemAuditActor,
+				luggageAudit(models.AuditLuggageStatusChanged, &old, luggage, "")); err != nil {
+				return err
+			}
+			changed++
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// ListOverdueLuggage 本酒店逾期 / 无人认领的行李，status 为空时两者都返回
+func (s *LuggageService) ListOverdueLuggage(hotelID uint, status string, filter LuggageFilter) ([]models.Luggage, error) {
+	statuses := []string{models.LuggageStatusOverdue, models.LuggageStatusAbandoned}
+	if status != "" {
+		if status != models.LuggageStatusOverdue && status != models.LuggageStatusAbandoned {
+			return nil, fmt.Errorf("invalid status '%s', expected overdue or abandoned", status)
+		}
+		statuses = []string{status}
+	}
+
+	var luggages []models.Luggage
+	if err := database.DB.Scopes(database.HotelScope(hotelID), filter.Scope).
+		Where("status IN ?", statuses).
+		Order("stored_at ASC, id ASC").
+		Find(&luggages).Error; err != nil {
+		return nil, err
+	}
+	return luggages, nil
+}
+
+// DisposeLuggage 处置逾期行李（disposition 为 disposed 或 lost_and_found），
+// 行李离开寄存室、不再占用容量，取件码下没有其他在存行李时释放取件码；操作写入审计事件
+func (s *LuggageService) DisposeLuggage(id uint, disposition string, req DisposeLuggageRequest, hotelID uint, actor Actor) (*models.Luggage, error) {
+	if disposition != models.LuggageStatusDisposed && disposition != models.LuggageStatusLostAndFound {
+		return nil, fmt.Errorf("invalid disposition '%s'", disposition)
+	}
+
+	var luggage models.Luggage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(database.HotelScope(hotelID)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&luggage).Error; err != nil {
+			return errors.New("luggage not found in this hotel")
+		}
+		if luggage.Status != models.LuggageStatusOverdue && luggage.Status != models.LuggageStatusAbandoned {
+			return ErrLuggageNotOverdue
+		}
+
+		old := luggage
+		now := time.Now()
+		if err := tx.Model(&luggage).Scopes(database.HotelScope(hotelID)).
+			Updates(map[string]interface{}{
+				"status":      disposition,
+				"disposed_at": now,
+				"disposed_by": actor.Username,
+			}).Error; err != nil {
+			return err
+		}
+		luggage.Status = disposition
+		luggage.DisposedAt = &now
+		luggage.DisposedBy = actor.Username
+
+		if err := s.releaseRetrievalCode(tx, hotelID, luggage.RetrievalCode); err != nil {
+			return err
+		}
+
+		// 审计事件中附带处置说明
+		return recordAudit(tx, hotelID, actor, luggageAudit(models.AuditLuggageDisposed, &old, luggage, req.Note))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &luggage, nil
+}
+
+// StartOverdueJob 后台定期标记逾期 / 无人认领的行李（启动后执行一次，之后每小时一次）
+func StartOverdueJob() {
+	go func() {
+		for {
+			if overdue, abandoned, err := NewLuggageService().FlagOverdueLuggage(); err != nil {
+				log.Printf("Overdue job failed: %v", err)
+			} else if overdue > 0 || abandoned > 0 {
+				log.Printf("Overdue job flagged %d overdue and %d abandoned luggage records", overdue, abandoned)
+			}
+			time.Sleep(overdueJobInterval)
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// luggageListSpec 行李列表（寄存室订单、行李搜索）可用的过滤列和排序列
+var luggageListSpec = listSpec{
+	timeColumn:      "stored_at",
+	guestColumn:     "guest_name",
+	staffColumn:     "staff_name",
+	luggageIDColumn: "id",
+	sortColumns:     map[string]string{"stored_at": "stored_at", "guest_name": "guest_name", "room_number": "room_number"},
+}
+
+// luggageSortKey 按排序字段取行李的排序值
+func luggageSortKey(l models.Luggage, sort string) (interface{}, uint) {
+	switch sort {
+	case "guest_name":
+		return l.GuestName, l.ID
+	case "room_number":
+		return l.RoomNumber, l.ID
+	}
+	return l.StoredAt, l.ID
+}
+
+// LuggageSearch 行李搜索条件，未设置的字段不过滤。
+// 客人姓名、经办人、寄存时间范围、分页和排序使用 ListQuery；房号、预订号、预计取件时间使用 LuggageFilter
+type LuggageSearch struct {
+	LuggageFilter
+	PhoneSuffix   string   // 联系电话后几位
+	Keywords      string   // 描述 / 特殊说明关键词，空格分隔，全部匹配
+	Statuses      []string // 为空时不限状态
+	StoreroomID   uint
+	RetrievedFrom *time.Time // 取件时间范围 [RetrievedFrom, RetrievedTo)
+	RetrievedTo   *time.Time
+}
+
+// Scope 把搜索条件加到行李查询上
+func (f LuggageSearch) Scope(db *gorm.DB) *gorm.DB {
+	db = f.LuggageFilter.Scope(db)
+	if f.PhoneSuffix != "" {
+		db = db.Where("contact_phone LIKE ?", "%"+escapeLike(f.PhoneSuffix))
+	}
+	if query := fulltextQuery(f.Keywords); query != "" {
+		db = db.Where("MATCH(description, special_notes) AGAINST (? IN BOOLEAN MODE)", query)
+	}
+	if len(f.Statuses) > 0 {
+		db = db.Where("status IN ?", f.Statuses)
+	}
+	if f.StoreroomID != 0 {
+		db = db.Where("storeroom_id = ?", f.StoreroomID)
+	}
+	if f.RetrievedFrom != nil {
+		db = db.Where("retrieved_at >= ?", *f.RetrievedFrom)
+	}
+	if f.RetrievedTo != nil {
+		db = db.Where("retrieved_at < ?", *f.RetrievedTo)
+	}
+	return db
+}
+
+// fulltextQuery 把关键词转为 BOOLEAN MODE 查询：去掉全文检索运算符，每个词都必须出现（+"词"）
+func fulltextQuery(keywords string) string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, keywords)
+
+	terms := strings.Fields(clean)
+	for i, term := range terms {
+		terms[i] = `+"` + term + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// validateStatuses 校验状态过滤值
+func validateStatuses(statuses []string) error {
+	for _, status := range statuses {
+		valid := false
+		for _, s := range models.LuggageStatuses {
+			if s == status {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid status '%s', expected one of %s", status, strings.Join(models.LuggageStatuses, ", "))
+		}
+	}
+	return nil
+}
+
+// SearchLuggage 按多种条件搜索本酒店的行李（含已取出 / 已处置的记录），游标分页
+func (s *LuggageService) SearchLuggage(hotelID uint, search LuggageSearch, q ListQuery) (*ListPage[models.Luggage], error) {
+	if err := validateStatuses(search.Statuses); err != nil {
+		return nil, err
+	}
+	query := database.DB.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID), search.Scope)
+	return paginate(query, hotelID, q, luggageListSpec, luggageSortKey)
+}
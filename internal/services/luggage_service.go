@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"luggage-sys2/internal/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LuggageService struct{}
@@ -20,64 +22,118 @@ func NewLuggageService() *LuggageService {
 	return &LuggageService{}
 }
 
+// checkoutIdempotencyTTL 取件幂等记录保留时间，超过后同一 key 视为新请求
+const checkoutIdempotencyTTL = 24 * time.Hour
+
+var (
+	ErrStoreroomFull           = errors.New("storeroom is full")
+	ErrLuggageAlreadyRetrieved = errors.New("luggage has already been retrieved")
+	ErrIdempotencyKeyReused    = errors.New("idempotency key was already used for another retrieval code")
+	ErrRetrievalCodeTypo       = errors.New("retrieval code check digit mismatch, please check the code")
+	ErrNoStoredLuggageForCode  = errors.New("no stored luggage found for this code in this hotel")
+)
+
+const (
+	maxRetrievalCodeAttempts = 10 // 取件码冲突时的最大重试次数
+	checkoutPINLength        = 6
+	maxRoomNumberLength      = 32
+	maxReservationIDLength   = 64
+)
+
 type LuggageItem struct {
 	StoreroomID  uint     `json:"storeroom_id" binding:"required"`
+	SlotID       *uint    `json:"slot_id"` // 指定格位，可选
 	Description  string   `json:"description"`
 	Quantity     int      `json:"quantity"`
+	SizeClass    string   `json:"size_class"` // small（默认）、large、oversize
 	SpecialNotes string   `json:"special_notes"`
 	PhotoURLs    []string `json:"photo_urls"`
 	PhotoURL     string   `json:"photo_url"`
 }
 
 type CreateLuggageRequest struct {
-	GuestName    string        `json:"guest_name" binding:"required"`
-	StaffName    string        `json:"staff_name"`
-	ContactPhone string        `json:"contact_phone"`
-	ContactEmail string        `json:"contact_email"`
-	Description  string        `json:"description"`   // 单件模式
-	Quantity     int           `json:"quantity"`      // 单件模式
-	SpecialNotes string        `json:"special_notes"` // 单件模式
-	PhotoURLs    []string      `json:"photo_urls"`    // 单件模式
-	PhotoURL     string        `json:"photo_url"`     // 单件模式
-	StoreroomID  uint          `json:"storeroom_id"`  // 单件模式（多件模式时不需要）
-	Items        []LuggageItem `json:"items"`         // 多件模式
+	GuestName     string        `json:"guest_name" binding:"required"`
+	StaffName     string        `json:"staff_name"`
+	ContactPhone  string        `json:"contact_phone"`
+	ContactEmail  string        `json:"contact_email"`
+	RoomNumber    string        `json:"room_number"`
+	ReservationID string        `json:"reservation_id"` // 预订号 / 账单（folio）号
+	Description   string        `json:"description"`    // 单件模式
+	Quantity      int           `json:"quantity"`       // 单件模式
+	SizeClass     string        `json:"size_class"`     // 单件模式
+	SpecialNotes  string        `json:"special_notes"`  // 单件模式
+	PhotoURLs     []string      `json:"photo_urls"`     // 单件模式
+	PhotoURL      string        `json:"photo_url"`      // 单件模式
+	StoreroomID   uint          `json:"storeroom_id"`   // 单件模式（多件模式时不需要）
+	SlotID        *uint         `json:"slot_id"`        // 单件模式，指定格位
+	Items         []LuggageItem `json:"items"`          // 多件模式
+
+	AutoAssignSlot bool `json:"auto_assign_slot"` // 未指定 slot_id 的行李自动分配寄存室内下一个空闲格位
+
+	ExpectedPickupAt *time.Time `json:"expected_pickup_at"` // 预计取件时间（RFC 3339），可选
 }
 
 type UpdateLuggageRequest struct {
-	GuestName    string   `json:"guest_name"`
-	ContactPhone string   `json:"contact_phone"`
-	Description  string   `json:"description"`
-	SpecialNotes string   `json:"special_notes"`
-	PhotoURLs    []string `json:"photo_urls"`
-	PhotoURL     string   `json:"photo_url"`
+	GuestName        string     `json:"guest_name"`
+	ContactPhone     string     `json:"contact_phone"`
+	RoomNumber       string     `json:"room_number"`
+	ReservationID    string     `json:"reservation_id"`
+	ExpectedPickupAt *time.Time `json:"expected_pickup_at"`
+	Description      string     `json:"description"`
+	SpecialNotes     string     `json:"special_notes"`
+	PhotoURLs        []string   `json:"photo_urls"`
+	PhotoURL         string     `json:"photo_url"`
+}
+
+// reserveRetrievalCode 在事务内为本次寄存分配取件码：写入 active_retrieval_codes，
+// 由 (hotel_id, code) 唯一索引保证在用取件码不重复，冲突时换一个重试
+func (s *LuggageService) reserveRetrievalCode(tx *gorm.DB, hotel *models.Hotel, pinHash string) (string, error) {
+	for i := 0; i < maxRetrievalCodeAttempts; i++ {
+		code, err := utils.GenerateRetrievalCode(hotel.RetrievalCodeAlphabet, hotel.RetrievalCodeLength)
+		if err != nil {
+			return "", err
+		}
+		// 使用保存点，冲突时只回滚这一条插入
+		err = tx.Transaction(func(sp *gorm.DB) error {
+			return sp.Create(&models.ActiveRetrievalCode{HotelID: hotel.ID, Code: code, PINHash: pinHash}).Error
+		})
+		if err == nil {
+			return code, nil
+		}
+		if !s.isDuplicateKeyError(err) {
+			return "", err
+		}
+	}
+	return "", errors.New("no free retrieval code available, please increase retrieval_code_length")
+}
+
+// releaseRetrievalCode 取件码下已没有在存行李时释放占用，之后可再次分配
+func (s *LuggageService) releaseRetrievalCode(tx *gorm.DB, hotelID uint, code string) error {
+	var count int64
+	if err := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+		Where("retrieval_code = ? AND status IN ?", code, models.InStorageStatuses).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Scopes(database.HotelScope(hotelID)).
+		Where("code = ?", code).
+		Delete(&models.ActiveRetrievalCode{}).Error
 }
 
-// generateUniqueRetrievalCode 生成唯一的取件码（检查数据库中是否已存在）
-// 如果提供了事务，则在事务内检查；否则使用普通连接检查
-func (s *LuggageService) generateUniqueRetrievalCode(tx *gorm.DB) string {
-	if tx == nil {
-		tx = database.DB
-	}
-	maxAttempts := 200 // 增加尝试次数
-	for i := 0; i < maxAttempts; i++ {
-		code := utils.GenerateRetrievalCode()
-		// 检查数据库中是否已存在该取件码
-		// 使用普通查询（不使用事务隔离），确保能看到已提交的数据
-		var count int64
-		if tx == database.DB {
-			// 如果是普通连接，直接查询
-			database.DB.Model(&models.Luggage{}).Where("retrieval_code = ?", code).Count(&count)
-		} else {
-			// 如果是事务，也查询主连接以确保看到已提交的数据
-			database.DB.Model(&models.Luggage{}).Where("retrieval_code = ?", code).Count(&count)
-		}
-		if count == 0 {
-			return code
-		}
-	}
-	// 如果200次都失败，生成一个更长的数字码（12位）
-	code := utils.GenerateRetrievalCode() + utils.GenerateRetrievalCode()
-	return code
+// normalizeRetrievalCode 去除空白并转为大写（取件码字符集只包含数字和大写字母）
+func normalizeRetrievalCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// retrievalCodeNotFound 没有找到取件码时，校验位不正确说明是输错了，给出更明确的提示
+func retrievalCodeNotFound(code string, hotel *models.Hotel, notFound error) error {
+	if hotel != nil && !utils.ValidRetrievalCodeCheck(code, hotel.RetrievalCodeAlphabet) {
+		return ErrRetrievalCodeTypo
+	}
+	return notFound
 }
 
 // isDuplicateKeyError 检查是否是重复键错误
@@ -90,352 +146,513 @@ func (s *LuggageService) isDuplicateKeyError(err error) bool {
 	return strings.Contains(errStr, "Duplicate entry") || strings.Contains(errStr, "1062")
 }
 
-func (s *LuggageService) CreateLuggage(req CreateLuggageRequest, hotelID uint) (*models.Luggage, string, error) {
-	// 判断是单件模式还是多件模式
-	if len(req.Items) > 0 {
-		// 多件模式：创建多个行李记录，共用同一个取件码
-		// 使用事务确保原子性：要么全部成功，要么全部回滚
-		tx := database.DB.Begin()
-		defer func() {
-			if r := recover(); r != nil {
-				tx.Rollback()
-			}
-		}()
-
-		// 在事务内生成唯一的取件码（多件模式时，所有行李共用同一个取件码）
-		retrievalCode := s.generateUniqueRetrievalCode(nil)
-
-		var firstLuggage *models.Luggage
-		for _, item := range req.Items {
-			// 检查寄存室是否存在
-			var storeroom models.Storeroom
-			if err := tx.Where("id = ? AND hotel_id = ? AND is_active = ?", item.StoreroomID, hotelID, true).First(&storeroom).Error; err != nil {
-				// 提供更详细的错误信息，帮助定位问题
-				// 先检查寄存室是否存在（不检查 hotel_id 和 is_active）
-				var checkStoreroom models.Storeroom
-				if err2 := tx.Where("id = ?", item.StoreroomID).First(&checkStoreroom).Error; err2 != nil {
-					tx.Rollback()
-					return nil, "", fmt.Errorf("storeroom not found: storeroom_id %d does not exist", item.StoreroomID)
-				}
-				// 检查是否属于当前酒店
-				if checkStoreroom.HotelID != hotelID {
-					tx.Rollback()
-					return nil, "", fmt.Errorf("storeroom not found: storeroom_id %d does not belong to your hotel (hotel_id: %d)", item.StoreroomID, hotelID)
-				}
-				// 检查是否启用
-				if !checkStoreroom.IsActive {
-					tx.Rollback()
-					return nil, "", fmt.Errorf("storeroom not found: storeroom_id %d is not active", item.StoreroomID)
-				}
-				tx.Rollback()
-				return nil, "", fmt.Errorf("storeroom not found: storeroom_id %d", item.StoreroomID)
-			}
-
-			// 检查容量
-			var count int64
-			tx.Model(&models.Luggage{}).Where("storeroom_id = ? AND status = ?", item.StoreroomID, "stored").Count(&count)
-			if int(count) >= storeroom.Capacity {
-				tx.Rollback()
-				return nil, "", errors.New("storeroom is full")
-			}
-
-			// 处理图片
-			photoURLs := item.PhotoURLs
-			if len(photoURLs) == 0 && item.PhotoURL != "" {
-				photoURLs = []string{item.PhotoURL}
-			}
-			photoURL := item.PhotoURL
-			if photoURL == "" && len(photoURLs) > 0 {
-				photoURL = photoURLs[0]
-			}
-
-			// 设置数量，如果未设置则默认为1
-			quantity := item.Quantity
-			if quantity <= 0 {
-				quantity = 1
-			}
+// CreateLuggage 寄存行李。单件模式按一件处理，与多件模式走同一流程：
+// 在事务内锁定涉及的寄存室后再检查容量并写入，并发寄存不会超出容量。
+// 酒店启用 PIN 验证时同时返回一次性取件 PIN（只在此时返回明文）
+func (s *LuggageService) CreateLuggage(req CreateLuggageRequest, hotelID uint, actor Actor) (*models.Luggage, string, string, error) {
+	settings, err := NewHotelService().GetHotel(hotelID)
+	if err != nil {
+		return nil, "", "", err
+	}
 
-			// 创建行李记录
-			luggage := models.Luggage{
-				GuestName:     req.GuestName,
-				StaffName:     req.StaffName,
-				ContactPhone:  req.ContactPhone,
-				ContactEmail:  req.ContactEmail,
-				Description:   item.Description,
-				Quantity:      quantity,
-				SpecialNotes:  item.SpecialNotes,
-				PhotoURLs:     models.StringSlice(photoURLs),
-				PhotoURL:      photoURL,
-				StoreroomID:   item.StoreroomID,
-				RetrievalCode: retrievalCode, // 共用同一个取件码
-				Status:        "stored",
-			}
+	// 判断是单件模式还是多件模式
+	items := req.Items
+	if len(items) == 0 {
+		// 单件模式：验证必填字段
+		if req.StoreroomID == 0 {
+			return nil, "", "", errors.New("storeroom_id is required in single-item mode")
+		}
+		items = []LuggageItem{{
+			StoreroomID:  req.StoreroomID,
+			SlotID:       req.SlotID,
+			Description:  req.Description,
+			Quantity:     req.Quantity,
+			SizeClass:    req.SizeClass,
+			SpecialNotes: req.SpecialNotes,
+			PhotoURLs:    req.PhotoURLs,
+			PhotoURL:     req.PhotoURL,
+		}}
+	}
 
-			// 直接插入，多个行李可以共用同一个取件码
-			if err := tx.Create(&luggage).Error; err != nil {
-				tx.Rollback()
-				return nil, "", err
-			}
+	if err := normalizeItems(items); err != nil {
+		return nil, "", "", err
+	}
 
-			// 保存第一个创建的行李记录用于返回
-			if firstLuggage == nil {
-				firstLuggage = &luggage
-			}
+	if req.ExpectedPickupAt != nil && !req.ExpectedPickupAt.After(time.Now()) {
+		return nil, "", "", errors.New("expected_pickup_at must be in the future")
+	}
+	if err := validateBookingRef(req.RoomNumber, req.ReservationID); err != nil {
+		return nil, "", "", err
+	}
 
-			// 创建寄存记录
-			storedLog := models.StoredLog{
-				HotelID:   hotelID,
-				LuggageID: luggage.ID,
-				GuestName: luggage.GuestName,
-				Status:    "stored",
-			}
-			if err := tx.Create(&storedLog).Error; err != nil {
-				tx.Rollback()
-				return nil, "", err
-			}
+	// 取件需要 PIN 时生成一次性 PIN，只保存摘要
+	var pin, pinHash string
+	if settings.CheckoutVerification == models.CheckoutVerifyPIN {
+		if pin, err = utils.GenerateNumericPIN(checkoutPINLength); err != nil {
+			return nil, "", "", err
 		}
-
-		// 提交事务
-		if err := tx.Commit().Error; err != nil {
-			return nil, "", err
+		if pinHash, err = utils.HashPassword(pin); err != nil {
+			return nil, "", "", err
 		}
+	}
 
-		return firstLuggage, retrievalCode, nil
-	} else {
-		// 单件模式：原有逻辑
-		// 验证单件模式必填字段
-		if req.StoreroomID == 0 {
-			return nil, "", errors.New("storeroom_id is required in single-item mode")
+	// 使用事务确保原子性：要么全部成功，要么全部回滚
+	var firstLuggage *models.Luggage
+	var retrievalCode string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 分配取件码（多件模式时，所有行李共用同一个取件码）
+		code, err := s.reserveRetrievalCode(tx, settings, pinHash)
+		if err != nil {
+			return err
 		}
-
-		// 检查寄存室是否存在
-		var storeroom models.Storeroom
-		if err := database.DB.Where("id = ? AND hotel_id = ? AND is_active = ?", req.StoreroomID, hotelID, true).First(&storeroom).Error; err != nil {
-			return nil, "", errors.New("storeroom not found")
+		retrievalCode = code
+
+		created, err := s.storeItems(tx, hotelID, models.Luggage{
+			HotelID:          hotelID,
+			GuestName:        req.GuestName,
+			StaffName:        req.StaffName,
+			ContactPhone:     req.ContactPhone,
+			ContactEmail:     req.ContactEmail,
+			RoomNumber:       strings.TrimSpace(req.RoomNumber),
+			ReservationID:    strings.TrimSpace(req.ReservationID),
+			RetrievalCode:    retrievalCode, // 共用同一个取件码
+			ExpectedPickupAt: req.ExpectedPickupAt,
+		}, items, req.AutoAssignSlot, actor)
+		if err != nil {
+			return err
 		}
 
-		// 检查容量
-		var count int64
-		database.DB.Model(&models.Luggage{}).Where("storeroom_id = ? AND status = ?", req.StoreroomID, "stored").Count(&count)
-		if int(count) >= storeroom.Capacity {
-			return nil, "", errors.New("storeroom is full")
-		}
+		// 保存第一个创建的行李记录用于返回
+		firstLuggage = &created[0]
+		return nil
+	})
+	if err != nil {
+		return nil, "", "", err
+	}
 
-		// 生成唯一的取件码（单件模式）
-		retrievalCode := s.generateUniqueRetrievalCode(nil)
+	NewNotificationService().NotifyDeposit(hotelID, retrievalCode, pin)
 
-		// multi-photo compatibility:
-		// - prefer photo_urls when provided
-		// - fallback to photo_url (single) -> photo_urls=[photo_url]
-		photoURLs := req.PhotoURLs
-		if len(photoURLs) == 0 && req.PhotoURL != "" {
-			photoURLs = []string{req.PhotoURL}
-		}
-		photoURL := req.PhotoURL
-		if photoURL == "" && len(photoURLs) > 0 {
-			photoURL = photoURLs[0]
-		}
+	return firstLuggage, retrievalCode, pin, nil
+}
 
-		// 设置数量，如果未设置则默认为1
-		quantity := req.Quantity
-		if quantity <= 0 {
-			quantity = 1
-		}
+// validateBookingRef 校验房号和预订号长度
+func validateBookingRef(roomNumber, reservationID string) error {
+	if len(strings.TrimSpace(roomNumber)) > maxRoomNumberLength {
+		return fmt.Errorf("room_number must not exceed %d characters", maxRoomNumberLength)
+	}
+	if len(strings.TrimSpace(reservationID)) > maxReservationIDLength {
+		return fmt.Errorf("reservation_id must not exceed %d characters", maxReservationIDLength)
+	}
+	return nil
+}
 
-		luggage := models.Luggage{
-			GuestName:     req.GuestName,
-			StaffName:     req.StaffName,
-			ContactPhone:  req.ContactPhone,
-			ContactEmail:  req.ContactEmail,
-			Description:   req.Description,
-			Quantity:      quantity,
-			SpecialNotes:  req.SpecialNotes,
-			PhotoURLs:     models.StringSlice(photoURLs),
-			PhotoURL:      photoURL,
-			StoreroomID:   req.StoreroomID,
-			RetrievalCode: retrievalCode,
-			Status:        "stored",
+// validateSizeClass 校验行李尺寸分类
+func validateSizeClass(sizeClass string) error {
+	for _, class := range models.SizeClasses {
+		if sizeClass == class {
+			return nil
 		}
+	}
+	return fmt.Errorf("invalid size_class '%s', expected small, large or oversize", sizeClass)
+}
 
-		if err := database.DB.Create(&luggage).Error; err != nil {
-			return nil, "", err
-		}
+// lockStorerooms 按 ID 升序锁定本次寄存涉及的寄存室（SELECT ... FOR UPDATE），
+// 同一寄存室的并发寄存排队执行，固定加锁顺序避免死锁
+func (s *LuggageService) lockStorerooms(tx *gorm.DB, hotelID uint, ids []uint) (map[uint]models.Storeroom, error) {
+	sorted := append([]uint(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
 
-		// 创建寄存记录
-		storedLog := models.StoredLog{
-			HotelID:   hotelID,
-			LuggageID: luggage.ID,
-			GuestName: luggage.GuestName,
-			Status:    "stored",
+	storerooms := make(map[uint]models.Storeroom, len(sorted))
+	for _, id := range sorted {
+		if _, ok := storerooms[id]; ok {
+			continue
+		}
+		var storeroom models.Storeroom
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND hotel_id = ? AND is_active = ?", id, hotelID, true).
+			First(&storeroom).Error; err != nil {
+			return nil, s.storeroomNotFoundError(tx, id, hotelID)
 		}
-		database.DB.Create(&storedLog)
+		storerooms[id] = storeroom
+	}
+	return storerooms, nil
+}
 
-		return &luggage, retrievalCode, nil
+// storeroomNotFoundError 提供更详细的错误信息，帮助定位问题
+func (s *LuggageService) storeroomNotFoundError(tx *gorm.DB, id uint, hotelID uint) error {
+	// 先检查寄存室是否存在（不检查 hotel_id 和 is_active）
+	var storeroom models.Storeroom
+	if err := tx.Where("id = ?", id).First(&storeroom).Error; err != nil {
+		return fmt.Errorf("storeroom not found: storeroom_id %d does not exist", id)
+	}
+	// 检查是否属于当前酒店
+	if storeroom.HotelID != hotelID {
+		return fmt.Errorf("storeroom not found: storeroom_id %d does not belong to your hotel (hotel_id: %d)", id, hotelID)
+	}
+	// 检查是否启用
+	if !storeroom.IsActive {
+		return fmt.Errorf("storeroom not found: storeroom_id %d is not active", id)
 	}
+	return fmt.Errorf("storeroom not found: storeroom_id %d", id)
 }
 
+// GetLuggageByCode 按取件码查询行李。取件码在行李全部取走后可能被重新分配，
+// 因此有在存行李时只返回在存的行李（按 ID 排序，即部分取件时的行李序号），否则返回该取件码的历史记录
 func (s *LuggageService) GetLuggageByCode(code string, hotelID uint) ([]models.Luggage, error) {
+	code = normalizeRetrievalCode(code)
+
 	var luggages []models.Luggage
-	if err := database.DB.Where("retrieval_code = ?", code).Find(&luggages).Error; err != nil {
+	if err := database.DB.Scopes(database.HotelScope(hotelID)).
+		Where("retrieval_code = ?", code).
+		Order("stored_at DESC, id ASC").
+		Find(&luggages).Error; err != nil {
 		return nil, err
 	}
 
 	if len(luggages) == 0 {
-		return nil, errors.New("luggage not found")
+		hotel, _ := NewHotelService().GetHotel(hotelID)
+		return nil, retrievalCodeNotFound(code, hotel, errors.New("luggage not found in this hotel"))
 	}
 
-	// 验证是否属于当前酒店（检查所有行李的寄存室）
-	validLuggages := make([]models.Luggage, 0)
+	stored := make([]models.Luggage, 0, len(luggages))
 	for _, luggage := range luggages {
-		var storeroom models.Storeroom
-		if err := database.DB.Where("id = ? AND hotel_id = ?", luggage.StoreroomID, hotelID).First(&storeroom).Error; err == nil {
-			validLuggages = append(validLuggages, luggage)
+		if models.IsInStorage(luggage.Status) {
+			stored = append(stored, luggage)
 		}
 	}
-
-	if len(validLuggages) == 0 {
-		return nil, errors.New("luggage not found in this hotel")
+	if len(stored) > 0 {
+		sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+		return stored, nil
 	}
-
-	return validLuggages, nil
+	return luggages, nil
 }
 
-func (s *LuggageService) CheckoutLuggage(code string, username string, hotelID uint) ([]uint, error) {
-	// 获取所有同取件码的行李记录
-	var luggages []models.Luggage
-	if err := database.DB.Where("retrieval_code = ?", code).Find(&luggages).Error; err != nil {
-		return nil, errors.New("luggage not found")
+// CheckoutLuggage 取走取件码下的在存行李（默认全部，req 指定 luggage_ids / item_indexes 时只取走这部分），
+// 返回取走的行李 ID 和取件码下剩余的在存行李；还有剩余行李时取件码继续有效。
+// 在事务内锁定行李行并按在存状态条件更新，并发取件时只有一个请求成功，其余返回 ErrLuggageAlreadyRetrieved；
+// 传入 idempotencyKey 时记录本次结果，同一 key 重试直接返回首次结果；
+// 酒店启用二次验证时先核对 req.Verification，失败次数过多会锁定取件码
+func (s *LuggageService) CheckoutLuggage(code string, req CheckoutLuggageRequest, hotelID uint, actor Actor, idempotencyKey string) ([]uint, []models.Luggage, error) {
+	code = normalizeRetrievalCode(code)
+
+	if len(req.LuggageIDs) > 0 && len(req.ItemIndexes) > 0 {
+		return nil, nil, errors.New("luggage_ids and item_indexes cannot be used together")
 	}
 
-	if len(luggages) == 0 {
-		return nil, errors.New("luggage not found")
+	if idempotencyKey != "" {
+		if ids, found, err := s.findCheckoutByKey(code, hotelID, idempotencyKey); found || err != nil {
+			return s.checkoutResult(code, hotelID, ids, err)
+		}
+	}
+
+	hotel, err := NewHotelService().GetHotel(hotelID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 验证是否属于当前酒店并取走所有在存状态的行李
 	var retrievedIDs []uint
-	now := time.Now()
+	var verificationErr error
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定本酒店同取件码且在存状态（含逾期、无人认领）的行李记录
+		var luggages []models.Luggage
+		if err := tx.Scopes(database.HotelScope(hotelID)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("retrieval_code = ? AND status IN ?", code, models.InStorageStatuses).
+			Find(&luggages).Error; err != nil {
+			return err
+		}
+
+		if len(luggages) == 0 {
+			var count int64
+			if err := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+				Where("retrieval_code = ?", code).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrLuggageAlreadyRetrieved
+			}
+			return retrievalCodeNotFound(code, hotel, ErrNoStoredLuggageForCode)
+		}
 
-	for i := range luggages {
-		luggage := &luggages[i]
+		// 二次验证：未通过时提交失败次数，不取走行李
+		if err := s.verifyCheckout(tx, hotel, code, luggages[0], req.Verification); err != nil {
+			if isVerificationError(err) {
+				verificationErr = err
+				return nil
+			}
+			return err
+		}
 
-		// 验证是否属于当前酒店
-		var storeroom models.Storeroom
-		if err := database.DB.Where("id = ? AND hotel_id = ?", luggage.StoreroomID, hotelID).First(&storeroom).Error; err != nil {
-			continue // 跳过不属于当前酒店的行李
+		luggages, err := s.selectCheckoutItems(tx, hotelID, code, luggages, req)
+		if err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(luggages))
+		for _, luggage := range luggages {
+			ids = append(ids, luggage.ID)
 		}
 
-		// 只取走在存状态的行李
-		if luggage.Status != "stored" {
-			continue
+		// 条件更新：只有仍为在存状态的行李才会被取走
+		now := time.Now()
+		result := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+			Where("id IN ? AND status IN ?", ids, models.InStorageStatuses).
+			Updates(map[string]interface{}{
+				"status":       models.LuggageStatusRetrieved,
+				"retrieved_at": now,
+				"retrieved_by": actor.Username,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(ids)) {
+			return ErrLuggageAlreadyRetrieved
 		}
 
-		// 更新状态
-		luggage.Status = "retrieved"
-		luggage.RetrievedAt = &now
-		luggage.RetrievedBy = username
+		// 行李已全部取走时释放取件码，部分取件时取件码继续有效
+		if err := s.releaseRetrievalCode(tx, hotelID, code); err != nil {
+			return err
+		}
 
-		if err := database.DB.Save(luggage).Error; err != nil {
-			return nil, err
+		if idempotencyKey != "" {
+			// 同一 key 的过期记录尚未被定期清理时先删除，否则唯一索引冲突导致取件回滚
+			if err := tx.Where("hotel_id = ? AND idempotency_key = ? AND created_at <= ?",
+				hotelID, idempotencyKey, now.Add(-checkoutIdempotencyTTL)).
+				Delete(&models.CheckoutIdempotency{}).Error; err != nil {
+				return err
+			}
+			idsJSON, _ := json.Marshal(ids)
+			if err := tx.Create(&models.CheckoutIdempotency{
+				HotelID:        hotelID,
+				IdempotencyKey: idempotencyKey,
+				RetrievalCode:  code,
+				LuggageIDs:     string(idsJSON),
+				RetrievedBy:    actor.Username,
+			}).Error; err != nil {
+				return err
+			}
 		}
 
-		retrievedIDs = append(retrievedIDs, luggage.ID)
+		// 审计事件最后写入：哈希链头的行锁在事务最后获取，持有时间最短
+		records := make([]auditRecord, 0, len(luggages))
+		for _, luggage := range luggages {
+			retrieved := luggage
+			retrieved.Status = models.LuggageStatusRetrieved
+			retrieved.RetrievedAt = &now
+			retrieved.RetrievedBy = actor.Username
+			records = append(records, luggageAudit(models.AuditLuggageRetrieved, &luggage, retrieved, ""))
+		}
+		if err := recordAudit(tx, hotelID, actor, records...); err != nil {
+			return err
+		}
 
-		// 创建取出记录
-		retrievedLog := models.RetrievedLog{
-			HotelID:     hotelID,
-			LuggageID:   luggage.ID,
-			GuestName:   luggage.GuestName,
-			RetrievedBy: username,
+		retrievedIDs = ids
+		return nil
+	})
+	if err != nil {
+		// 同一 key 的并发重试：另一个请求已经完成取件，返回它的结果
+		if idempotencyKey != "" && (errors.Is(err, ErrLuggageAlreadyRetrieved) || s.isDuplicateKeyError(err)) {
+			if ids, found, lookupErr := s.findCheckoutByKey(code, hotelID, idempotencyKey); found || lookupErr != nil {
+				return s.checkoutResult(code, hotelID, ids, lookupErr)
+			}
 		}
-		database.DB.Create(&retrievedLog)
+		return nil, nil, err
+	}
+	if verificationErr != nil {
+		return nil, nil, verificationErr
+	}
+
+	NewNotificationService().NotifyCheckout(hotelID, code, retrievedIDs)
+
+	return s.checkoutResult(code, hotelID, retrievedIDs, nil)
+}
+
+// selectCheckoutItems 从已锁定的在存行李中选出本次要取走的行李（请求未指定时为全部）
+func (s *LuggageService) selectCheckoutItems(tx *gorm.DB, hotelID uint, code string, stored []models.Luggage, req CheckoutLuggageRequest) ([]models.Luggage, error) {
+	if len(req.LuggageIDs) == 0 && len(req.ItemIndexes) == 0 {
+		return stored, nil
 	}
 
-	if len(retrievedIDs) == 0 {
-		return nil, errors.New("no stored luggage found for this code in this hotel")
+	// 序号与按取件码查询的返回顺序一致：在存行李按 ID 排序
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ID < stored[j].ID })
+
+	selected := make([]models.Luggage, 0, len(stored))
+	seen := make(map[uint]bool)
+	if len(req.ItemIndexes) > 0 {
+		for _, index := range req.ItemIndexes {
+			if index < 1 || index > len(stored) {
+				return nil, fmt.Errorf("item index %d out of range, %d items stored under this code", index, len(stored))
+			}
+			if l := stored[index-1]; !seen[l.ID] {
+				seen[l.ID] = true
+				selected = append(selected, l)
+			}
+		}
+		return selected, nil
 	}
 
-	return retrievedIDs, nil
+	byID := make(map[uint]models.Luggage, len(stored))
+	for _, l := range stored {
+		byID[l.ID] = l
+	}
+	for _, id := range req.LuggageIDs {
+		l, ok := byID[id]
+		if !ok {
+			// 区分已取走和不属于该取件码
+			var count int64
+			if err := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+				Where("id = ? AND retrieval_code = ?", id, code).
+				Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				return nil, fmt.Errorf("%w: luggage %d", ErrLuggageAlreadyRetrieved, id)
+			}
+			return nil, fmt.Errorf("luggage %d is not stored under this retrieval code", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			selected = append(selected, l)
+		}
+	}
+	return selected, nil
+}
+
+// checkoutResult 组装取件结果：本次取走的行李 ID 和取件码下剩余的在存行李
+func (s *LuggageService) checkoutResult(code string, hotelID uint, ids []uint, err error) ([]uint, []models.Luggage, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	var remaining []models.Luggage
+	if err := database.DB.Scopes(database.HotelScope(hotelID)).
+		Where("retrieval_code = ? AND status IN ?", code, models.InStorageStatuses).
+		Order("id ASC").
+		Find(&remaining).Error; err != nil {
+		return nil, nil, err
+	}
+	return ids, remaining, nil
+}
+
+// findCheckoutByKey 查找有效期内的幂等记录
+func (s *LuggageService) findCheckoutByKey(code string, hotelID uint, idempotencyKey string) ([]uint, bool, error) {
+	var record models.CheckoutIdempotency
+	err := database.DB.
+		Where("hotel_id = ? AND idempotency_key = ? AND created_at > ?", hotelID, idempotencyKey, time.Now().Add(-checkoutIdempotencyTTL)).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if record.RetrievalCode != code {
+		return nil, true, ErrIdempotencyKeyReused
+	}
+
+	var ids []uint
+	if err := json.Unmarshal([]byte(record.LuggageIDs), &ids); err != nil {
+		return nil, true, err
+	}
+	return ids, true, nil
+}
+
+// PurgeCheckoutIdempotencyKeys 清理过期的取件幂等记录
+func (s *LuggageService) PurgeCheckoutIdempotencyKeys() (int64, error) {
+	result := database.DB.
+		Where("created_at < ?", time.Now().Add(-checkoutIdempotencyTTL)).
+		Delete(&models.CheckoutIdempotency{})
+	return result.RowsAffected, result.Error
 }
 
 func (s *LuggageService) GetGuestList(hotelID uint) ([]string, error) {
 	var guestNames []string
-	if err := database.DB.Model(&models.Luggage{}).
+	if err := database.DB.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
 		Distinct("guest_name").
-		Joins("JOIN storerooms ON luggages.storeroom_id = storerooms.id").
-		Where("storerooms.hotel_id = ? AND luggages.status = ?", hotelID, "stored").
+		Where("status IN ?", models.InStorageStatuses).
 		Pluck("guest_name", &guestNames).Error; err != nil {
 		return nil, err
 	}
 	return guestNames, nil
 }
 
-func (s *LuggageService) GetLuggageByGuestName(guestName string, hotelID uint) ([]models.Luggage, error) {
+func (s *LuggageService) GetLuggageByGuestName(guestName string, hotelID uint, filter LuggageFilter) ([]models.Luggage, error) {
 	var luggages []models.Luggage
-	if err := database.DB.
-		Joins("JOIN storerooms ON luggages.storeroom_id = storerooms.id").
-		Where("luggages.guest_name = ? AND storerooms.hotel_id = ? AND luggages.status = ?", guestName, hotelID, "stored").
+	if err := database.DB.Scopes(database.HotelScope(hotelID), filter.Scope).
+		Where("guest_name = ? AND status IN ?", guestName, models.InStorageStatuses).
 		Find(&luggages).Error; err != nil {
 		return nil, err
 	}
 	return luggages, nil
 }
 
-func (s *LuggageService) UpdateLuggage(id uint, req UpdateLuggageRequest, hotelID uint, username string) error {
-	var luggage models.Luggage
-	if err := database.DB.Where("id = ?", id).First(&luggage).Error; err != nil {
-		return errors.New("invalid luggage id")
+// UpdateLuggage 修改行李信息，变更的字段与修改在同一事务中写入审计事件
+func (s *LuggageService) UpdateLuggage(id uint, req UpdateLuggageRequest, hotelID uint, actor Actor) error {
+	if err := validateBookingRef(req.RoomNumber, req.ReservationID); err != nil {
+		return err
 	}
 
-	// 验证是否属于当前酒店
-	var storeroom models.Storeroom
-	if err := database.DB.Where("id = ? AND hotel_id = ?", luggage.StoreroomID, hotelID).First(&storeroom).Error; err != nil {
-		return errors.New("luggage not found in this hotel")
-	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var luggage models.Luggage
+		if err := tx.Scopes(database.HotelScope(hotelID)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&luggage).Error; err != nil {
+			return errors.New("luggage not found in this hotel")
+		}
 
-	// 保存旧数据用于日志
-	oldData, _ := json.Marshal(luggage)
+		// 保存旧数据用于审计（图片列表单独复制，下面可能原地修改）
+		old := luggage
+		old.PhotoURLs = append(models.StringSlice(nil), luggage.PhotoURLs...)
 
-	// 更新字段
-	if req.GuestName != "" {
-		luggage.GuestName = req.GuestName
-	}
-	if req.ContactPhone != "" {
-		luggage.ContactPhone = req.ContactPhone
-	}
-	if req.Description != "" {
-		luggage.Description = req.Description
-	}
-	if req.SpecialNotes != "" {
-		luggage.SpecialNotes = req.SpecialNotes
-	}
-	// photo_urls has higher priority (replace all)
-	if len(req.PhotoURLs) > 0 {
-		luggage.PhotoURLs = models.StringSlice(req.PhotoURLs)
-		luggage.PhotoURL = req.PhotoURLs[0]
-	} else if req.PhotoURL != "" {
-		// backward compatibility: update single photo
-		luggage.PhotoURL = req.PhotoURL
-		if len(luggage.PhotoURLs) > 0 {
-			luggage.PhotoURLs[0] = req.PhotoURL
-		} else {
-			luggage.PhotoURLs = models.StringSlice{req.PhotoURL}
+		// 更新字段
+		if req.GuestName != "" {
+			luggage.GuestName = req.GuestName
+		}
+		if req.ContactPhone != "" {
+			luggage.ContactPhone = req.ContactPhone
+		}
+		if req.RoomNumber != "" {
+			luggage.RoomNumber = strings.TrimSpace(req.RoomNumber)
+		}
+		if req.ReservationID != "" {
+			luggage.ReservationID = strings.TrimSpace(req.ReservationID)
+		}
+		if req.ExpectedPickupAt != nil {
+			luggage.ExpectedPickupAt = req.ExpectedPickupAt
+			// 客人延长寄存：新的预计取件时间还没到时取消逾期标记
+			if luggage.Status == models.LuggageStatusOverdue && req.ExpectedPickupAt.After(time.Now()) {
+				luggage.Status = models.LuggageStatusStored
+				luggage.OverdueAt = nil
+			}
+		}
+		if req.Description != "" {
+			luggage.Description = req.Description
+		}
+		if req.SpecialNotes != "" {
+			luggage.SpecialNotes = req.SpecialNotes
+		}
+		// photo_urls has higher priority (replace all)
+		if len(req.PhotoURLs) > 0 {
+			luggage.PhotoURLs = models.StringSlice(req.PhotoURLs)
+			luggage.PhotoURL = req.PhotoURLs[0]
+		} else if req.PhotoURL != "" {
+			// backward compatibility: update single photo
+			luggage.PhotoURL = req.PhotoURL
+			if len(luggage.PhotoURLs) > 0 {
+				luggage.PhotoURLs[0] = req.PhotoURL
+			} else {
+				luggage.PhotoURLs = models.StringSlice{req.PhotoURL}
+			}
 		}
-	}
-
-	if err := database.DB.Save(&luggage).Error; err != nil {
-		return err
-	}
 
-	// 创建修改记录
-	newData, _ := json.Marshal(luggage)
-	updatedLog := models.UpdatedLog{
-		HotelID:   hotelID,
-		LuggageID: luggage.ID,
-		UpdatedBy: username,
-		OldData:   string(oldData),
-		NewData:   string(newData),
-	}
-	database.DB.Create(&updatedLog)
+		if err := tx.Scopes(database.HotelScope(hotelID)).Save(&luggage).Error; err != nil {
+			return err
+		}
 
-	return nil
+		// 没有字段变化时不写审计事件
+		record := luggageAudit(models.AuditLuggageUpdated, &old, luggage, "")
+		if len(record.Changes) == 0 {
+			return nil
+		}
+		return recordAudit(tx, hotelID, actor, record)
+	})
 }
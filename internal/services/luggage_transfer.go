@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSameStoreroom = errors.New("luggage is already in the target storeroom")
+
+// MoveLuggageRequest 移库：把行李移到本酒店另一个启用中的寄存室
+type MoveLuggageRequest struct {
+	ToStoreroomID uint   `json:"to_storeroom_id" binding:"required"`
+	Note          string `json:"note"` // 移库原因，写入审计事件
+}
+
+// MoveLuggage 把一件在存行李移到目标寄存室，检查目标寄存室容量并写入审计事件
+func (s *LuggageService) MoveLuggage(id uint, req MoveLuggageRequest, hotelID uint, actor Actor) (*models.Luggage, error) {
+	var moved []models.Luggage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var luggage models.Luggage
+		if err := tx.Scopes(database.HotelScope(hotelID)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(&luggage).Error; err != nil {
+			return errors.New("luggage not found in this hotel")
+		}
+		if !models.IsInStorage(luggage.Status) {
+			return ErrLuggageAlreadyRetrieved
+		}
+		if luggage.StoreroomID == req.ToStoreroomID {
+			return ErrSameStoreroom
+		}
+
+		var err error
+		moved, err = s.moveLuggages(tx, hotelID, []models.Luggage{luggage}, req.ToStoreroomID, actor, req.Note)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &moved[0], nil
+}
+
+// MoveStoreroomLuggage 把某寄存室内全部在存行李移到目标寄存室（整体移库），返回移动的行李；
+// 目标寄存室剩余容量不足以容纳全部行李时不移动任何行李
+func (s *LuggageService) MoveStoreroomLuggage(fromID uint, req MoveLuggageRequest, hotelID uint, actor Actor) ([]models.Luggage, error) {
+	if fromID == req.ToStoreroomID {
+		return nil, ErrSameStoreroom
+	}
+
+	var moved []models.Luggage
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		moved, err = s.moveStoreroomLuggage(tx, fromID, req, hotelID, actor)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// moveStoreroomLuggage 在事务内整体移库，停用寄存室时的强制转移也使用
+func (s *LuggageService) moveStoreroomLuggage(tx *gorm.DB, fromID uint, req MoveLuggageRequest, hotelID uint, actor Actor) ([]models.Luggage, error) {
+	// 源寄存室可以是已停用的，只要求属于本酒店
+	var from models.Storeroom
+	if err := tx.Where("id = ? AND hotel_id = ?", fromID, hotelID).First(&from).Error; err != nil {
+		return nil, errors.New("invalid storeroom id")
+	}
+
+	var luggages []models.Luggage
+	if err := tx.Scopes(database.HotelScope(hotelID)).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("storeroom_id = ? AND status IN ?", fromID, models.InStorageStatuses).
+		Order("id ASC").
+		Find(&luggages).Error; err != nil {
+		return nil, err
+	}
+	if len(luggages) == 0 {
+		return []models.Luggage{}, nil
+	}
+	return s.moveLuggages(tx, hotelID, luggages, req.ToStoreroomID, actor, req.Note)
+}
+
+// moveLuggages 在事务内把已锁定的行李移到目标寄存室：锁定目标寄存室后按总容量和尺寸分类容量检查，
+// 更新行李的寄存室并逐件写入移库审计事件
+func (s *LuggageService) moveLuggages(tx *gorm.DB, hotelID uint, luggages []models.Luggage, toID uint, actor Actor, note string) ([]models.Luggage, error) {
+	storerooms, err := s.lockStorerooms(tx, hotelID, []uint{toID})
+	if err != nil {
+		return nil, err
+	}
+	target := storerooms[toID]
+
+	usage, err := storedUnits(tx, hotelID, []uint{toID})
+	if err != nil {
+		return nil, err
+	}
+	used := usage[toID]
+	if used == nil {
+		used = make(map[string]int)
+	}
+	stored, moving := 0, 0
+	for _, units := range used {
+		stored += units
+	}
+	for _, luggage := range luggages {
+		moving += luggage.Quantity
+		used[luggage.SizeClass] += luggage.Quantity
+	}
+	if stored+moving > target.Capacity {
+		return nil, fmt.Errorf("%w: moving %d units, target storeroom has %d free", ErrStoreroomFull, moving, target.Capacity-stored)
+	}
+	for _, class := range models.SizeClasses {
+		if limit := target.SizeCapacity(class); limit != nil && used[class] > *limit {
+			return nil, fmt.Errorf("%w: no free %s slots", ErrStoreroomFull, class)
+		}
+	}
+
+	// 格位属于原寄存室，移库后清空
+	records := make([]auditRecord, 0, len(luggages))
+	for i := range luggages {
+		old := luggages[i]
+		if err := tx.Model(&luggages[i]).Scopes(database.HotelScope(hotelID)).
+			Updates(map[string]interface{}{"storeroom_id": toID, "slot_id": nil, "slot_code": ""}).Error; err != nil {
+			return nil, err
+		}
+		luggages[i].StoreroomID = toID
+		luggages[i].SlotID = nil
+		luggages[i].SlotCode = ""
+		records = append(records, luggageAudit(models.AuditLuggageMoved, &old, luggages[i], note))
+	}
+	if err := recordAudit(tx, hotelID, actor, records...); err != nil {
+		return nil, err
+	}
+	return luggages, nil
+}
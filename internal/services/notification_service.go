@@ -0,0 +1,437 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type NotificationService struct {
+	notifiers []Notifier
+}
+
+func NewNotificationService() *NotificationService {
+	return &NotificationService{
+		notifiers: configuredNotifiers(),
+	}
+}
+
+const (
+	reminderJobInterval     = time.Hour
+	maxNotificationLogLimit = 500
+	maxNotificationErrorLen = 500
+)
+
+// defaultNotificationTemplates 系统默认模板（邮件和短信共用正文），酒店可按事件 + 渠道覆盖
+var defaultNotificationTemplates = map[string]struct{ Subject, Body string }{
+	models.NotifyEventDeposit: {
+		Subject: "{{.HotelName}} 行李寄存凭证",
+		Body: "{{.GuestName}} 您好，您已在{{.HotelName}}寄存 {{.Quantity}} 件行李。\n" +
+			"取件码：{{.RetrievalCode}}\n" +
+			"{{if .PIN}}取件 PIN：{{.PIN}}\n{{end}}" +
+			"取件二维码：{{.QRCodeURL}}\n" +
+			"寄存时间：{{.StoredAt}}",
+	},
+	models.NotifyEventCheckout: {
+		Subject: "{{.HotelName}} 行李已取走",
+		Body: "{{.GuestName}} 您好，您在{{.HotelName}}寄存的 {{.Quantity}} 件行李（取件码 {{.RetrievalCode}}）已于 {{.RetrievedAt}} 取走。" +
+			"如非本人操作，请尽快联系前台。",
+	},
+	models.NotifyEventReminder: {
+		Subject: "{{.HotelName}} 行李领取提醒",
+		Body: "{{.GuestName}} 您好，您在{{.HotelName}}寄存的 {{.Quantity}} 件行李已存放 {{.StoredHours}} 小时，" +
+			"请凭取件码 {{.RetrievalCode}} 尽快领取。",
+	},
+}
+
+// NotificationData 通知模板可用的变量
+type NotificationData struct {
+	HotelName     string
+	GuestName     string
+	RetrievalCode string
+	QRCodeURL     string
+	PIN           string // 仅寄存通知，且酒店启用 PIN 验证时有值
+	ItemCount     int    // 行李记录数
+	Quantity      int    // 行李件数（quantity 之和）
+	StoredAt      string // 酒店时区，格式 2006-01-02 15:04
+	RetrievedAt   string // 仅取件通知
+	StoredHours   int
+}
+
+// NotificationTemplateView 某个事件 + 渠道当前生效的模板
+type NotificationTemplateView struct {
+	Event      string     `json:"event"`
+	Channel    string     `json:"channel"`
+	Subject    string     `json:"subject"`
+	Body       string     `json:"body"`
+	Customized bool       `json:"customized"` // false 表示使用系统默认模板
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+type SaveNotificationTemplateRequest struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body" binding:"required"`
+}
+
+func validateNotifyEvent(event string) error {
+	for _, e := range models.NotifyEvents {
+		if e == event {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid event '%s', expected deposit, checkout or reminder", event)
+}
+
+func validateNotifyChannel(channel string) error {
+	for _, c := range models.NotifyChannels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid channel '%s', expected email or sms", channel)
+}
+
+// qrCodeURL 通知中的二维码链接，配置了 PUBLIC_BASE_URL 时为绝对地址
+func qrCodeURL(code string) string {
+	return config.PublicBaseURL + "/qr/" + code
+}
+
+// renderNotification 渲染模板
+func renderNotification(text string, data NotificationData) (string, error) {
+	tpl, err := template.New("notification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// NotifyDeposit 寄存成功后异步通知客人取件码和二维码链接（酒店启用 PIN 验证时附带 PIN）
+func (s *NotificationService) NotifyDeposit(hotelID uint, code string, pin string) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+	go func() {
+		var luggages []models.Luggage
+		if err := database.DB.Scopes(database.HotelScope(hotelID)).
+			Where("retrieval_code = ? AND status IN ?", code, models.InStorageStatuses).
+			Order("id ASC").
+			Find(&luggages).Error; err != nil {
+			log.Printf("Notification: load luggage for code %s failed: %v", code, err)
+			return
+		}
+		if _, err := s.notify(hotelID, models.NotifyEventDeposit, code, luggages, pin); err != nil {
+			log.Printf("Notification: deposit notification for code %s failed: %v", code, err)
+		}
+	}()
+}
+
+// NotifyCheckout 取件完成后异步通知客人
+func (s *NotificationService) NotifyCheckout(hotelID uint, code string, luggageIDs []uint) {
+	if len(s.notifiers) == 0 || len(luggageIDs) == 0 {
+		return
+	}
+	go func() {
+		var luggages []models.Luggage
+		if err := database.DB.Scopes(database.HotelScope(hotelID)).
+			Where("id IN ?", luggageIDs).
+			Order("id ASC").
+			Find(&luggages).Error; err != nil {
+			log.Printf("Notification: load luggage for code %s failed: %v", code, err)
+			return
+		}
+		if _, err := s.notify(hotelID, models.NotifyEventCheckout, code, luggages, ""); err != nil {
+			log.Printf("Notification: checkout notification for code %s failed: %v", code, err)
+		}
+	}()
+}
+
+// notify 按酒店模板通过所有已配置的渠道通知客人，每次发送写入 notification_logs，返回发送次数；
+// 酒店未启用通知或客人没有留对应联系方式时跳过
+func (s *NotificationService) notify(hotelID uint, event string, code string, luggages []models.Luggage, pin string) (int, error) {
+	if len(luggages) == 0 {
+		return 0, nil
+	}
+	hotel, err := NewHotelService().GetHotel(hotelID)
+	if err != nil {
+		return 0, err
+	}
+	if !hotel.NotifyGuests {
+		return 0, nil
+	}
+
+	loc := hotel.Location()
+	first := luggages[0]
+	data := NotificationData{
+		HotelName:     hotel.Name,
+		GuestName:     first.GuestName,
+		RetrievalCode: code,
+		QRCodeURL:     qrCodeURL(code),
+		PIN:           pin,
+		ItemCount:     len(luggages),
+		StoredAt:      first.StoredAt.In(loc).Format("2006-01-02 15:04"),
+		StoredHours:   int(time.Since(first.StoredAt).Hours()),
+	}
+	for _, l := range luggages {
+		data.Quantity += l.Quantity
+		if l.RetrievedAt != nil {
+			data.RetrievedAt = l.RetrievedAt.In(loc).Format("2006-01-02 15:04")
+		}
+	}
+
+	templates, err := s.effectiveTemplates(hotelID)
+	if err != nil {
+		return 0, err
+	}
+
+	attempts := 0
+	for _, n := range s.notifiers {
+		var to string
+		switch n.Channel() {
+		case models.NotifyChannelEmail:
+			to = strings.TrimSpace(first.ContactEmail)
+		case models.NotifyChannelSMS:
+			to = strings.TrimSpace(first.ContactPhone)
+		}
+		if to == "" {
+			continue
+		}
+		attempts++
+
+		tpl := templates[event+"/"+n.Channel()]
+		subject, err := renderNotification(tpl.Subject, data)
+		var body string
+		if err == nil {
+			body, err = renderNotification(tpl.Body, data)
+		}
+		if err == nil {
+			err = n.Send(to, subject, body)
+		}
+
+		record := models.NotificationLog{
+			HotelID:       hotelID,
+			RetrievalCode: code,
+			Event:         event,
+			Channel:       n.Channel(),
+			Recipient:     to,
+			Status:        models.NotifyStatusSent,
+		}
+		if err != nil {
+			record.Status = models.NotifyStatusFailed
+			record.Error = err.Error()
+			if len(record.Error) > maxNotificationErrorLen {
+				record.Error = strings.ToValidUTF8(record.Error[:maxNotificationErrorLen], "")
+			}
+			log.Printf("Notification: %s %s to %s failed: %v", event, n.Channel(), to, err)
+		}
+		if err := database.DB.Create(&record).Error; err != nil {
+			log.Printf("Notification: save delivery log failed: %v", err)
+		}
+	}
+	return attempts, nil
+}
+
+// effectiveTemplates 酒店各事件 + 渠道当前生效的模板，key 为 "event/channel"
+func (s *NotificationService) effectiveTemplates(hotelID uint) (map[string]NotificationTemplateView, error) {
+	var custom []models.NotificationTemplate
+	if err := database.DB.Where("hotel_id = ?", hotelID).Find(&custom).Error; err != nil {
+		return nil, err
+	}
+
+	views := make(map[string]NotificationTemplateView, len(models.NotifyEvents)*len(models.NotifyChannels))
+	for _, event := range models.NotifyEvents {
+		for _, channel := range models.NotifyChannels {
+			def := defaultNotificationTemplates[event]
+			views[event+"/"+channel] = NotificationTemplateView{
+				Event:   event,
+				Channel: channel,
+				Subject: def.Subject,
+				Body:    def.Body,
+			}
+		}
+	}
+	for _, t := range custom {
+		updatedAt := t.UpdatedAt
+		views[t.Event+"/"+t.Channel] = NotificationTemplateView{
+			Event:      t.Event,
+			Channel:    t.Channel,
+			Subject:    t.Subject,
+			Body:       t.Body,
+			Customized: true,
+			UpdatedAt:  &updatedAt,
+		}
+	}
+	return views, nil
+}
+
+// ListTemplates 本酒店所有事件 + 渠道当前生效的模板
+func (s *NotificationService) ListTemplates(hotelID uint) ([]NotificationTemplateView, error) {
+	views, err := s.effectiveTemplates(hotelID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]NotificationTemplateView, 0, len(views))
+	for _, event := range models.NotifyEvents {
+		for _, channel := range models.NotifyChannels {
+			items = append(items, views[event+"/"+channel])
+		}
+	}
+	return items, nil
+}
+
+// SaveTemplate 保存酒店自定义模板，保存前用示例数据试渲染，避免发送时才发现模板错误
+func (s *NotificationService) SaveTemplate(hotelID uint, event, channel string, req SaveNotificationTemplateRequest) (*models.NotificationTemplate, error) {
+	if err := validateNotifyEvent(event); err != nil {
+		return nil, err
+	}
+	if err := validateNotifyChannel(channel); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, errors.New("body is empty")
+	}
+	sample := NotificationData{
+		HotelName:     "Hotel",
+		GuestName:     "Guest",
+		RetrievalCode: "123456",
+		QRCodeURL:     qrCodeURL("123456"),
+		PIN:           "000000",
+		ItemCount:     1,
+		Quantity:      1,
+		StoredAt:      "2006-01-02 15:04",
+		RetrievedAt:   "2006-01-02 15:04",
+		StoredHours:   1,
+	}
+	for field, text := range map[string]string{"subject": req.Subject, "body": req.Body} {
+		if _, err := renderNotification(text, sample); err != nil {
+			return nil, fmt.Errorf("invalid %s template: %v", field, err)
+		}
+	}
+
+	var tpl models.NotificationTemplate
+	err := database.DB.Where("hotel_id = ? AND event = ? AND channel = ?", hotelID, event, channel).First(&tpl).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	tpl.HotelID = hotelID
+	tpl.Event = event
+	tpl.Channel = channel
+	tpl.Subject = req.Subject
+	tpl.Body = req.Body
+	if err := database.DB.Save(&tpl).Error; err != nil {
+		return nil, errors.New("save notification template failed")
+	}
+	return &tpl, nil
+}
+
+// ResetTemplate 删除酒店自定义模板，恢复系统默认模板
+func (s *NotificationService) ResetTemplate(hotelID uint, event, channel string) error {
+	if err := validateNotifyEvent(event); err != nil {
+		return err
+	}
+	if err := validateNotifyChannel(channel); err != nil {
+		return err
+	}
+	return database.DB.
+		Where("hotel_id = ? AND event = ? AND channel = ?", hotelID, event, channel).
+		Delete(&models.NotificationTemplate{}).Error
+}
+
+// ListLogs 本酒店通知发送记录，可按取件码过滤
+func (s *NotificationService) ListLogs(hotelID uint, code string, limit int) ([]models.NotificationLog, error) {
+	if limit <= 0 || limit > maxNotificationLogLimit {
+		limit = maxNotificationLogLimit
+	}
+	query := database.DB.Where("hotel_id = ?", hotelID)
+	if code != "" {
+		query = query.Where("retrieval_code = ?", normalizeRetrievalCode(code))
+	}
+	var logs []models.NotificationLog
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// SendReminders 提醒存放超过酒店设置时长的客人领取行李，每个取件码只提醒一次（发送失败也不重发），返回提醒的取件码数量
+func (s *NotificationService) SendReminders() (int, error) {
+	if len(s.notifiers) == 0 {
+		return 0, nil
+	}
+
+	var hotels []models.Hotel
+	if err := database.DB.
+		Where("is_active = ? AND notify_guests = ? AND reminder_after_hours > 0", true, true).
+		Find(&hotels).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, hotel := range hotels {
+		cutoff := time.Now().Add(-time.Duration(hotel.ReminderAfterHours) * time.Hour)
+		var luggages []models.Luggage
+		if err := database.DB.Scopes(database.HotelScope(hotel.ID)).
+			Where("status IN ? AND stored_at < ?", models.InStorageStatuses, cutoff).
+			Order("retrieval_code ASC, stored_at ASC, id ASC").
+			Find(&luggages).Error; err != nil {
+			return sent, err
+		}
+
+		// 按取件码分组（已按取件码排序）
+		for start := 0; start < len(luggages); {
+			end := start
+			for end < len(luggages) && luggages[end].RetrievalCode == luggages[start].RetrievalCode {
+				end++
+			}
+			group := luggages[start:end]
+			start = end
+
+			// 同一取件码可能被回收复用，只看本次寄存之后是否已提醒过
+			code := group[0].RetrievalCode
+			var count int64
+			if err := database.DB.Model(&models.NotificationLog{}).
+				Where("hotel_id = ? AND retrieval_code = ? AND event = ? AND created_at >= ?",
+					hotel.ID, code, models.NotifyEventReminder, group[0].StoredAt).
+				Count(&count).Error; err != nil {
+				return sent, err
+			}
+			if count > 0 {
+				continue
+			}
+			attempts, err := s.notify(hotel.ID, models.NotifyEventReminder, code, group, "")
+			if err != nil {
+				return sent, err
+			}
+			if attempts > 0 {
+				sent++
+			}
+		}
+	}
+	return sent, nil
+}
+
+// StartReminderJob 后台定期提醒客人领取行李（启动后执行一次，之后每小时一次）
+func StartReminderJob() {
+	go func() {
+		for {
+			if n, err := NewNotificationService().SendReminders(); err != nil {
+				log.Printf("Reminder job failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Reminder job notified %d retrieval codes", n)
+			}
+			time.Sleep(reminderJobInterval)
+		}
+	}()
+}
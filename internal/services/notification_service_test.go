@@ -0,0 +1,87 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"luggage-sys2/internal/models"
+)
+
+func TestRenderNotification(t *testing.T) {
+	data := NotificationData{
+		HotelName:     "海景酒店",
+		GuestName:     "张三",
+		RetrievalCode: "4827361",
+		QRCodeURL:     qrCodeURL("4827361"),
+		Quantity:      2,
+		StoredAt:      "2026-10-17 09:30",
+	}
+
+	body, err := renderNotification(defaultNotificationTemplates[models.NotifyEventDeposit].Body, data)
+	if err != nil {
+		t.Fatalf("render deposit body: %v", err)
+	}
+	for _, want := range []string{"张三 您好", "海景酒店寄存 2 件行李", "取件码：4827361", "/qr/4827361", "寄存时间：2026-10-17 09:30"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("deposit body missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "PIN") {
+		t.Errorf("deposit body without PIN should skip the PIN line:\n%s", body)
+	}
+
+	data.PIN = "305519"
+	body, err = renderNotification(defaultNotificationTemplates[models.NotifyEventDeposit].Body, data)
+	if err != nil {
+		t.Fatalf("render deposit body with PIN: %v", err)
+	}
+	if !strings.Contains(body, "取件 PIN：305519") {
+		t.Errorf("deposit body missing PIN line:\n%s", body)
+	}
+
+	for event, tpl := range defaultNotificationTemplates {
+		for field, text := range map[string]string{"subject": tpl.Subject, "body": tpl.Body} {
+			if _, err := renderNotification(text, data); err != nil {
+				t.Errorf("default %s %s template: %v", event, field, err)
+			}
+		}
+	}
+}
+
+func TestRenderNotificationMissingKey(t *testing.T) {
+	if out, err := renderNotification("房号 {{.RoomNumber}}", NotificationData{}); err == nil {
+		t.Errorf("unknown variable rendered as %q, want error", out)
+	}
+}
+
+// 模板在试渲染阶段被拒绝，不会访问数据库
+func TestSaveTemplateRejectsInvalidTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		channel string
+		req     SaveNotificationTemplateRequest
+		wantErr string
+	}{
+		{"unknown field in body", models.NotifyEventDeposit, models.NotifyChannelSMS,
+			SaveNotificationTemplateRequest{Body: "房号 {{.RoomNumber}}"}, "invalid body template"},
+		{"unknown field in subject", models.NotifyEventDeposit, models.NotifyChannelEmail,
+			SaveNotificationTemplateRequest{Subject: "{{.Hotel}}", Body: "{{.GuestName}}"}, "invalid subject template"},
+		{"syntax error", models.NotifyEventCheckout, models.NotifyChannelEmail,
+			SaveNotificationTemplateRequest{Body: "{{.GuestName"}, "invalid body template"},
+		{"empty body", models.NotifyEventReminder, models.NotifyChannelSMS,
+			SaveNotificationTemplateRequest{Body: "  "}, "body is empty"},
+		{"invalid event", "pickup", models.NotifyChannelSMS,
+			SaveNotificationTemplateRequest{Body: "{{.GuestName}}"}, "invalid event"},
+		{"invalid channel", models.NotifyEventDeposit, "wechat",
+			SaveNotificationTemplateRequest{Body: "{{.GuestName}}"}, "invalid channel"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNotificationService().SaveTemplate(1, tt.event, tt.channel, tt.req)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SaveTemplate error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
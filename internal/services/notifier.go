@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/models"
+)
+
+// Notifier 客人通知发送渠道
+type Notifier interface {
+	// Channel 渠道名称：email / sms
+	Channel() string
+	// Send 发送一条通知，subject 只对邮件有效
+	Send(to, subject, body string) error
+}
+
+// configuredNotifiers 按配置启用的通知渠道
+func configuredNotifiers() []Notifier {
+	var notifiers []Notifier
+	if config.SMTPHost != "" {
+		notifiers = append(notifiers, &SMTPNotifier{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+			Timeout:  config.NotificationTimeout,
+		})
+	}
+	if config.SMSWebhookURL != "" {
+		notifiers = append(notifiers, &WebhookSMSNotifier{
+			URL:    config.SMSWebhookURL,
+			Token:  config.SMSWebhookToken,
+			Client: &http.Client{Timeout: config.NotificationTimeout},
+		})
+	}
+	return notifiers
+}
+
+// SMTPNotifier 通过 SMTP 发送邮件通知，服务器支持 STARTTLS 时自动启用
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+func (n *SMTPNotifier) Channel() string {
+	return models.NotifyChannelEmail
+}
+
+func (n *SMTPNotifier) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return errors.New("invalid email address")
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(n.Host, n.Port), n.Timeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(n.Timeout))
+
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// WebhookSMSNotifier 通过通用 webhook 发送短信：POST {"to": "...", "message": "..."} 给短信网关，
+// 配置了 Token 时附带 Authorization: Bearer <token>，网关返回 2xx 视为成功
+type WebhookSMSNotifier struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func (n *WebhookSMSNotifier) Channel() string {
+	return models.NotifyChannelSMS
+}
+
+func (n *WebhookSMSNotifier) Send(to, subject, body string) error {
+	payload, err := json.Marshal(map[string]string{
+		"to":      to,
+		"message": body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("sms webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
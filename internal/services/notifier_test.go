@@ -0,0 +1,79 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookSMSNotifierSend(t *testing.T) {
+	var (
+		gotMethod string
+		gotAuth   string
+		gotType   string
+		gotBody   map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotAuth = r.Header.Get("Authorization")
+		gotType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := &WebhookSMSNotifier{URL: srv.URL, Token: "secret", Client: srv.Client()}
+	if err := n.Send("+8613800000000", "ignored subject", "取件码：123456"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if gotMethod != http.MethodPost {
+		t.Errorf("method = %s, want POST", gotMethod)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer secret")
+	}
+	if gotType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", gotType)
+	}
+	want := map[string]string{"to": "+8613800000000", "message": "取件码：123456"}
+	if len(gotBody) != len(want) || gotBody["to"] != want["to"] || gotBody["message"] != want["message"] {
+		t.Errorf("payload = %v, want %v", gotBody, want)
+	}
+}
+
+func TestWebhookSMSNotifierSendWithoutToken(t *testing.T) {
+	gotAuth := "unset"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	n := &WebhookSMSNotifier{URL: srv.URL, Client: srv.Client()}
+	if err := n.Send("+8613800000000", "", "hello"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization = %q, want no header", gotAuth)
+	}
+}
+
+func TestWebhookSMSNotifierSendNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	n := &WebhookSMSNotifier{URL: srv.URL, Client: srv.Client()}
+	err := n.Send("+8613800000000", "", "hello")
+	if err == nil {
+		t.Fatal("Send succeeded, want error for 429 response")
+	}
+	if !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("error = %q, want status code and response detail", err)
+	}
+}
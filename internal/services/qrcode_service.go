@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+type QRCodeService struct{}
+
+func NewQRCodeService() *QRCodeService {
+	return &QRCodeService{}
+}
+
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+
+	QRCodeDefaultSize = 256
+	QRCodeMinSize     = 64
+	QRCodeMaxSize     = 1024
+)
+
+var (
+	ErrInvalidQRCodeFormat = errors.New("invalid format, expected png or svg")
+	ErrInvalidQRCodeLevel  = errors.New("invalid level, expected L, M, Q or H")
+	ErrInvalidQRCodeSize   = fmt.Errorf("invalid size, expected %d-%d", QRCodeMinSize, QRCodeMaxSize)
+)
+
+// QRCodeOptions 二维码生成参数
+type QRCodeOptions struct {
+	Format string // png / svg，默认 png
+	Size   int    // 边长（像素），默认 256
+	Level  string // 纠错等级 L / M / Q / H，默认 M
+}
+
+// parseRecoveryLevel 将 L/M/Q/H 转换为纠错等级
+func parseRecoveryLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "", "M":
+		return qrcode.Medium, nil
+	case "L":
+		return qrcode.Low, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	default:
+		return 0, ErrInvalidQRCodeLevel
+	}
+}
+
+// GenerateQRCode 生成取件码二维码，返回图片内容和 Content-Type
+func (s *QRCodeService) GenerateQRCode(content string, opts QRCodeOptions) ([]byte, string, error) {
+	if content == "" {
+		return nil, "", errors.New("code is empty")
+	}
+
+	size := opts.Size
+	if size == 0 {
+		size = QRCodeDefaultSize
+	}
+	if size < QRCodeMinSize || size > QRCodeMaxSize {
+		return nil, "", ErrInvalidQRCodeSize
+	}
+
+	level, err := parseRecoveryLevel(opts.Level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	qr, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch strings.ToLower(opts.Format) {
+	case "", QRCodeFormatPNG:
+		png, err := qr.PNG(size)
+		if err != nil {
+			return nil, "", err
+		}
+		return png, "image/png", nil
+	case QRCodeFormatSVG:
+		return renderQRCodeSVG(qr.Bitmap(), size), "image/svg+xml", nil
+	default:
+		return nil, "", ErrInvalidQRCodeFormat
+	}
+}
+
+// renderQRCodeSVG 将二维码矩阵渲染为 SVG，每个深色模块输出为一个单位方块
+func renderQRCodeSVG(bitmap [][]bool, size int) []byte {
+	modules := len(bitmap)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+	b.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return []byte(b.String())
+}
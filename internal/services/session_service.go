@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+	"luggage-sys2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+type SessionService struct{}
+
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// TokenPair 登录 / 刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token 有效期（秒）
+}
+
+// issueRefreshToken 为会话签发新的刷新令牌
+func (s *SessionService) issueRefreshToken(tx *gorm.DB, sessionID string, expiresAt time.Time) (string, error) {
+	raw, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	refreshToken := models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// CreateSession 登录成功后创建会话并签发令牌
+func (s *SessionService) CreateSession(user *models.User, clientIP, userAgent string) (*TokenPair, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	expiresAt := time.Now().Add(config.RefreshTokenTTL)
+	var refreshToken string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		session := models.Session{
+			SessionID: sessionID,
+			UserID:    user.ID,
+			HotelID:   user.HotelID,
+			ClientIP:  clientIP,
+			UserAgent: userAgent,
+			ExpiresAt: expiresAt,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		refreshToken, err = s.issueRefreshToken(tx, sessionID, expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, errors.New("create session failed")
+	}
+
+	accessToken, err := utils.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌对（轮换）。
+// 已使用过的刷新令牌再次出现说明可能被盗用，此时吊销整个会话。
+func (s *SessionService) Refresh(rawToken string) (*models.User, *TokenPair, error) {
+	var user models.User
+	var newRefreshToken string
+	var sessionID string
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Where("token_hash = ?", utils.HashToken(rawToken)).First(&token).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		sessionID = token.SessionID
+
+		var session models.Session
+		if err := tx.Where("session_id = ?", token.SessionID).First(&session).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(token.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// 条件更新保证同一刷新令牌只能成功使用一次
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return ErrAccountDisabled
+		}
+
+		var err error
+		newRefreshToken, err = s.issueRefreshToken(tx, session.SessionID, session.ExpiresAt)
+		return err
+	})
+	if reused {
+		_ = s.RevokeSession(sessionID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, err := utils.GenerateToken(&user, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &user, &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresIn:    int64(config.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// IsSessionActive 会话是否仍然有效（未吊销、未过期）
+func (s *SessionService) IsSessionActive(sessionID string) bool {
+	if sessionID == "" {
+		return false
+	}
+	var count int64
+	database.DB.Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// RevokeSession 吊销单个会话（登出）
+func (s *SessionService) RevokeSession(sessionID string) error {
+	if err := database.DB.Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return errors.New("revoke session failed")
+	}
+	return nil
+}
+
+// RevokeUserSessions 吊销用户的所有会话，exceptSessionID 非空时保留该会话
+func (s *SessionService) RevokeUserSessions(userID uint, exceptSessionID string) (int64, error) {
+	return revokeUserSessions(database.DB, userID, exceptSessionID)
+}
+
+// revokeUserSessions 在调用方的事务中吊销用户的会话，与账号变更一起提交
+func revokeUserSessions(tx *gorm.DB, userID uint, exceptSessionID string) (int64, error) {
+	query := tx.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+	result := query.Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, errors.New("revoke sessions failed")
+	}
+	return result.RowsAffected, nil
+}
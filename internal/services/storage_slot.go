@@ -0,0 +1,335 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxSlotCodeLength = 32
+
+// CreateStorageSlotsRequest 批量创建格位，如 {"codes": ["A-01", "A-02"], "capacity": 2}
+type CreateStorageSlotsRequest struct {
+	Codes    []string `json:"codes" binding:"required"`
+	Capacity int      `json:"capacity"` // 每个格位可放件数，默认 1
+}
+
+// UpdateStorageSlotRequest 修改格位，未传的字段保持不变
+type UpdateStorageSlotRequest struct {
+	Code     *string `json:"code"`
+	Capacity *int    `json:"capacity"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// ListSlots 寄存室内的格位及占用情况，按编号排序
+func (s *StoreroomService) ListSlots(storeroomID uint, hotelID uint) ([]models.StorageSlot, error) {
+	var storeroom models.Storeroom
+	if err := database.DB.Where("id = ? AND hotel_id = ?", storeroomID, hotelID).First(&storeroom).Error; err != nil {
+		return nil, errors.New("invalid storeroom id")
+	}
+	slots, err := loadSlots(database.DB, hotelID, []uint{storeroomID})
+	if err != nil {
+		return nil, err
+	}
+	if slots[storeroomID] == nil {
+		return []models.StorageSlot{}, nil
+	}
+	return slots[storeroomID], nil
+}
+
+// CreateSlots 在寄存室下批量创建格位，编号在寄存室内唯一
+func (s *StoreroomService) CreateSlots(storeroomID uint, req CreateStorageSlotsRequest, hotelID uint, actor Actor) ([]models.StorageSlot, error) {
+	if len(req.Codes) == 0 {
+		return nil, errors.New("codes is empty")
+	}
+	if req.Capacity == 0 {
+		req.Capacity = 1
+	}
+	if req.Capacity < 0 {
+		return nil, errors.New("capacity must be positive")
+	}
+
+	seen := make(map[string]bool, len(req.Codes))
+	codes := make([]string, 0, len(req.Codes))
+	slots := make([]models.StorageSlot, 0, len(req.Codes))
+	for _, code := range req.Codes {
+		code, err := normalizeSlotCode(code)
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("duplicate slot code '%s'", code)
+		}
+		seen[code] = true
+		codes = append(codes, code)
+		slots = append(slots, models.StorageSlot{
+			HotelID:     hotelID,
+			StoreroomID: storeroomID,
+			Code:        code,
+			Capacity:    req.Capacity,
+			IsActive:    true,
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var storeroom models.Storeroom
+		if err := tx.Where("id = ? AND hotel_id = ?", storeroomID, hotelID).First(&storeroom).Error; err != nil {
+			return errors.New("invalid storeroom id")
+		}
+
+		var existing []string
+		if err := tx.Model(&models.StorageSlot{}).
+			Where("storeroom_id = ? AND code IN ?", storeroomID, codes).
+			Pluck("code", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("slot code '%s' already exists in this storeroom", existing[0])
+		}
+
+		if err := tx.Create(&slots).Error; err != nil {
+			return errors.New("create storage slots failed")
+		}
+		records := make([]auditRecord, 0, len(slots))
+		for _, slot := range slots {
+			records = append(records, slotAudit(models.AuditSlotCreated, nil, slot))
+		}
+		return recordAudit(tx, hotelID, actor, records...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range slots {
+		slots[i].RemainingCapacity = slots[i].Capacity
+	}
+	return slots, nil
+}
+
+// UpdateSlot 修改格位：容量不能低于已存件数；改编号时同步更新格位内在存行李的编号快照
+func (s *StoreroomService) UpdateSlot(storeroomID, slotID uint, req UpdateStorageSlotRequest, hotelID uint, actor Actor) (*models.StorageSlot, error) {
+	var slot models.StorageSlot
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定寄存室，与寄存时的格位分配串行
+		var storeroom models.Storeroom
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND hotel_id = ?", storeroomID, hotelID).
+			First(&storeroom).Error; err != nil {
+			return errors.New("invalid storeroom id")
+		}
+		if err := tx.Where("id = ? AND storeroom_id = ? AND hotel_id = ?", slotID, storeroomID, hotelID).
+			First(&slot).Error; err != nil {
+			return errors.New("invalid slot id")
+		}
+		old := slot
+
+		renamed := false
+		if req.Code != nil {
+			code, err := normalizeSlotCode(*req.Code)
+			if err != nil {
+				return err
+			}
+			if code != slot.Code {
+				var count int64
+				if err := tx.Model(&models.StorageSlot{}).
+					Where("storeroom_id = ? AND code = ? AND id <> ?", storeroomID, code, slotID).
+					Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					return fmt.Errorf("slot code '%s' already exists in this storeroom", code)
+				}
+				slot.Code = code
+				renamed = true
+			}
+		}
+		if req.Capacity != nil {
+			if *req.Capacity <= 0 {
+				return errors.New("capacity must be positive")
+			}
+			slot.Capacity = *req.Capacity
+		}
+		if req.IsActive != nil {
+			slot.IsActive = *req.IsActive
+		}
+
+		usage, err := slotUnits(tx, hotelID, []uint{slotID})
+		if err != nil {
+			return err
+		}
+		slot.StoredCount = usage[slotID]
+		slot.RemainingCapacity = slot.Capacity - slot.StoredCount
+		// 只在修改容量时检查，已超出容量的格位仍可改编号或停用
+		if req.Capacity != nil && slot.RemainingCapacity < 0 {
+			return fmt.Errorf("capacity cannot be less than stored count %d", slot.StoredCount)
+		}
+
+		if err := tx.Save(&slot).Error; err != nil {
+			return errors.New("update storage slot failed")
+		}
+		if renamed {
+			if err := tx.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+				Where("slot_id = ? AND status IN ?", slotID, models.InStorageStatuses).
+				Update("slot_code", slot.Code).Error; err != nil {
+				return err
+			}
+		}
+		record := slotAudit(models.AuditSlotUpdated, &old, slot)
+		if len(record.Changes) == 0 {
+			return nil
+		}
+		return recordAudit(tx, hotelID, actor, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &slot, nil
+}
+
+// slotAudit 格位变更的审计事件，old 为 nil 表示新建
+func slotAudit(action string, old *models.StorageSlot, slot models.StorageSlot) auditRecord {
+	var changes models.AuditChanges
+	if old == nil {
+		changes = models.DiffFields(nil, slot, slotAuditIgnore...)
+	} else {
+		changes = models.DiffFields(*old, slot, slotAuditIgnore...)
+	}
+	return auditRecord{
+		Action:     action,
+		EntityType: models.AuditEntitySlot,
+		EntityID:   slot.ID,
+		EntityName: slot.Code,
+		Changes:    changes,
+	}
+}
+
+func normalizeSlotCode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", errors.New("slot code cannot be empty")
+	}
+	if len(code) > maxSlotCodeLength {
+		return "", fmt.Errorf("slot code must not exceed %d characters", maxSlotCodeLength)
+	}
+	return code, nil
+}
+
+// loadSlots 按寄存室加载格位并填充占用，格位按编号排序
+func loadSlots(db *gorm.DB, hotelID uint, storeroomIDs []uint) (map[uint][]models.StorageSlot, error) {
+	result := make(map[uint][]models.StorageSlot, len(storeroomIDs))
+	if len(storeroomIDs) == 0 {
+		return result, nil
+	}
+
+	var slots []models.StorageSlot
+	if err := db.Where("hotel_id = ? AND storeroom_id IN ?", hotelID, storeroomIDs).
+		Order("code ASC, id ASC").
+		Find(&slots).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(slots))
+	for _, slot := range slots {
+		ids = append(ids, slot.ID)
+	}
+	usage, err := slotUnits(db, hotelID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range slots {
+		slot.StoredCount = usage[slot.ID]
+		slot.RemainingCapacity = slot.Capacity - slot.StoredCount
+		result[slot.StoreroomID] = append(result[slot.StoreroomID], slot)
+	}
+	return result, nil
+}
+
+// slotUnits 按格位统计在存件数（quantity 之和）
+func slotUnits(db *gorm.DB, hotelID uint, slotIDs []uint) (map[uint]int, error) {
+	usage := make(map[uint]int, len(slotIDs))
+	if len(slotIDs) == 0 {
+		return usage, nil
+	}
+
+	var rows []struct {
+		SlotID uint
+		Units  int
+	}
+	if err := db.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+		Select("slot_id, COALESCE(SUM(quantity), 0) AS units").
+		Where("slot_id IN ? AND status IN ?", slotIDs, models.InStorageStatuses).
+		Group("slot_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		usage[row.SlotID] = row.Units
+	}
+	return usage, nil
+}
+
+// slotAllocator 寄存时为行李分配格位，调用前寄存室行已锁定，同一寄存室的分配在事务内串行；
+// 同一批行李的占用在内存中累加
+type slotAllocator struct {
+	tx      *gorm.DB
+	hotelID uint
+	slots   map[uint][]models.StorageSlot // storeroom_id -> 格位（含占用）
+}
+
+func newSlotAllocator(tx *gorm.DB, hotelID uint) *slotAllocator {
+	return &slotAllocator{tx: tx, hotelID: hotelID, slots: make(map[uint][]models.StorageSlot)}
+}
+
+// assign 返回分配的格位：指定了 slotID 时校验该格位；否则 auto 为 true 时按编号顺序取第一个放得下的启用格位。
+// 寄存室未划分格位且未指定格位时返回 nil
+func (a *slotAllocator) assign(storeroomID uint, slotID *uint, quantity int, auto bool) (*models.StorageSlot, error) {
+	if slotID == nil && !auto {
+		return nil, nil
+	}
+	slots, ok := a.slots[storeroomID]
+	if !ok {
+		loaded, err := loadSlots(a.tx, a.hotelID, []uint{storeroomID})
+		if err != nil {
+			return nil, err
+		}
+		slots = loaded[storeroomID]
+		a.slots[storeroomID] = slots
+	}
+
+	if slotID != nil {
+		for i := range slots {
+			if slots[i].ID != *slotID {
+				continue
+			}
+			if !slots[i].IsActive {
+				return nil, fmt.Errorf("slot '%s' is inactive", slots[i].Code)
+			}
+			if slots[i].RemainingCapacity < quantity {
+				return nil, fmt.Errorf("%w: slot '%s' has %d free", ErrStoreroomFull, slots[i].Code, slots[i].RemainingCapacity)
+			}
+			return a.take(&slots[i], quantity), nil
+		}
+		return nil, fmt.Errorf("slot_id %d does not belong to storeroom_id %d", *slotID, storeroomID)
+	}
+
+	if len(slots) == 0 {
+		return nil, nil
+	}
+	for i := range slots {
+		if slots[i].IsActive && slots[i].RemainingCapacity >= quantity {
+			return a.take(&slots[i], quantity), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no free slot for %d units", ErrStoreroomFull, quantity)
+}
+
+func (a *slotAllocator) take(slot *models.StorageSlot, quantity int) *models.StorageSlot {
+	slot.StoredCount += quantity
+	slot.RemainingCapacity -= quantity
+	taken := *slot
+	return &taken
+}
@@ -2,9 +2,15 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"luggage-sys2/internal/database"
 	"luggage-sys2/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type StoreroomService struct{}
@@ -14,80 +20,340 @@ func NewStoreroomService() *StoreroomService {
 }
 
 type CreateStoreroomRequest struct {
-	Name     string `json:"name" binding:"required"`
-	Location string `json:"location"`
-	Capacity int    `json:"capacity" binding:"required"`
-	IsActive bool   `json:"is_active"`
+	Name             string `json:"name" binding:"required"`
+	Location         string `json:"location"`
+	Capacity         int    `json:"capacity" binding:"required"` // 总件数
+	SmallCapacity    *int   `json:"small_capacity"`              // 各尺寸分类件数，可选
+	LargeCapacity    *int   `json:"large_capacity"`
+	OversizeCapacity *int   `json:"oversize_capacity"`
+	IsActive         bool   `json:"is_active"`
 }
 
+// UpdateStoreroomRequest 修改寄存室，未传的字段保持不变；
+// 尺寸分类容量传 -1 表示取消该分类限制
 type UpdateStoreroomRequest struct {
-	IsActive bool `json:"is_active"`
+	Name             *string `json:"name"`
+	Location         *string `json:"location"`
+	Capacity         *int    `json:"capacity"`
+	SmallCapacity    *int    `json:"small_capacity"`
+	LargeCapacity    *int    `json:"large_capacity"`
+	OversizeCapacity *int    `json:"oversize_capacity"`
+	IsActive         *bool   `json:"is_active"`
+	// 停用时寄存室内仍有行李，可指定目标寄存室把行李一并移走，否则拒绝停用
+	TransferToStoreroomID *uint `json:"transfer_to_storeroom_id"`
+}
+
+// DeleteStoreroomRequest 删除寄存室，寄存室内仍有行李时需指定目标寄存室
+type DeleteStoreroomRequest struct {
+	TransferToStoreroomID *uint `json:"transfer_to_storeroom_id"`
 }
 
+var ErrStoreroomNotEmpty = errors.New("storeroom still has luggage in storage, move it out or set transfer_to_storeroom_id")
+
 func (s *StoreroomService) ListStorerooms(hotelID uint) ([]models.Storeroom, error) {
 	var storerooms []models.Storeroom
 	if err := database.DB.Where("hotel_id = ?", hotelID).Find(&storerooms).Error; err != nil {
 		return nil, err
 	}
 
-	// 计算每个寄存室的已存数量和剩余容量
+	// 计算每个寄存室的已存件数、剩余容量和各格位占用
+	ids := make([]uint, 0, len(storerooms))
+	for _, storeroom := range storerooms {
+		ids = append(ids, storeroom.ID)
+	}
+	usage, err := storedUnits(database.DB, hotelID, ids)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := loadSlots(database.DB, hotelID, ids)
+	if err != nil {
+		return nil, err
+	}
 	for i := range storerooms {
-		var count int64
-		database.DB.Model(&models.Luggage{}).
-			Where("storeroom_id = ? AND status = ?", storerooms[i].ID, "stored").
-			Count(&count)
-		storerooms[i].StoredCount = int(count)
-		storerooms[i].RemainingCapacity = storerooms[i].Capacity - int(count)
+		storerooms[i].ApplyUsage(usage[storerooms[i].ID])
+		storerooms[i].Slots = slots[storerooms[i].ID]
 	}
 
 	return storerooms, nil
 }
 
-func (s *StoreroomService) CreateStoreroom(req CreateStoreroomRequest, hotelID uint) (*models.Storeroom, error) {
+// storedUnits 按寄存室和尺寸分类统计在存件数（quantity 之和）
+func storedUnits(db *gorm.DB, hotelID uint, storeroomIDs []uint) (map[uint]map[string]int, error) {
+	usage := make(map[uint]map[string]int, len(storeroomIDs))
+	if len(storeroomIDs) == 0 {
+		return usage, nil
+	}
+
+	var rows []struct {
+		StoreroomID uint
+		SizeClass   string
+		Units       int
+	}
+	if err := db.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID)).
+		Select("storeroom_id, size_class, COALESCE(SUM(quantity), 0) AS units").
+		Where("storeroom_id IN ? AND status IN ?", storeroomIDs, models.InStorageStatuses).
+		Group("storeroom_id, size_class").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if usage[row.StoreroomID] == nil {
+			usage[row.StoreroomID] = make(map[string]int)
+		}
+		usage[row.StoreroomID][row.SizeClass] += row.Units
+	}
+	return usage, nil
+}
+
+// validateCapacities 校验总容量和各尺寸分类容量
+func validateCapacities(capacity int, sizeCapacities map[string]*int) error {
+	if capacity <= 0 {
+		return errors.New("capacity must be positive")
+	}
+	for class, limit := range sizeCapacities {
+		if limit != nil && (*limit < 0 || *limit > capacity) {
+			return fmt.Errorf("%s_capacity must be between 0 and capacity", class)
+		}
+	}
+	return nil
+}
+
+// CreateStoreroom 新建寄存室，与审计事件在同一事务中写入
+func (s *StoreroomService) CreateStoreroom(req CreateStoreroomRequest, hotelID uint, actor Actor) (*models.Storeroom, error) {
+	if err := validateCapacities(req.Capacity, map[string]*int{
+		models.SizeSmall:    req.SmallCapacity,
+		models.SizeLarge:    req.LargeCapacity,
+		models.SizeOversize: req.OversizeCapacity,
+	}); err != nil {
+		return nil, err
+	}
+
 	storeroom := models.Storeroom{
-		HotelID:  hotelID,
-		Name:     req.Name,
-		Location: req.Location,
-		Capacity: req.Capacity,
-		IsActive: req.IsActive,
+		HotelID:          hotelID,
+		Name:             req.Name,
+		Location:         req.Location,
+		Capacity:         req.Capacity,
+		SmallCapacity:    req.SmallCapacity,
+		LargeCapacity:    req.LargeCapacity,
+		OversizeCapacity: req.OversizeCapacity,
+		IsActive:         req.IsActive,
 	}
 
-	if err := database.DB.Create(&storeroom).Error; err != nil {
-		return nil, errors.New("create storeroom failed")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&storeroom).Error; err != nil {
+			return errors.New("create storeroom failed")
+		}
+		return recordAudit(tx, hotelID, actor, storeroomAudit(models.AuditStoreroomCreated, nil, storeroom, ""))
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &storeroom, nil
 }
 
-func (s *StoreroomService) UpdateStoreroom(id uint, req UpdateStoreroomRequest, hotelID uint) error {
+// UpdateStoreroom 按 PATCH 语义修改寄存室：容量不能低于已存件数；
+// 停用仍有行李的寄存室时必须同时指定 transfer_to_storeroom_id，行李在同一事务中移走
+func (s *StoreroomService) UpdateStoreroom(id uint, req UpdateStoreroomRequest, hotelID uint, actor Actor) (*models.Storeroom, error) {
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return nil, errors.New("name cannot be empty")
+	}
+
 	var storeroom models.Storeroom
-	if err := database.DB.Where("id = ? AND hotel_id = ?", id, hotelID).First(&storeroom).Error; err != nil {
-		return errors.New("invalid storeroom id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND hotel_id = ?", id, hotelID).
+			First(&storeroom).Error; err != nil {
+			return errors.New("invalid storeroom id")
+		}
+		old := storeroom
+
+		if req.Name != nil {
+			storeroom.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Location != nil {
+			storeroom.Location = *req.Location
+		}
+		if req.Capacity != nil {
+			storeroom.Capacity = *req.Capacity
+		}
+		applySizeCapacity(&storeroom.SmallCapacity, req.SmallCapacity)
+		applySizeCapacity(&storeroom.LargeCapacity, req.LargeCapacity)
+		applySizeCapacity(&storeroom.OversizeCapacity, req.OversizeCapacity)
+		if err := validateCapacities(storeroom.Capacity, map[string]*int{
+			models.SizeSmall:    storeroom.SmallCapacity,
+			models.SizeLarge:    storeroom.LargeCapacity,
+			models.SizeOversize: storeroom.OversizeCapacity,
+		}); err != nil {
+			return err
+		}
+
+		if req.IsActive != nil && !*req.IsActive && storeroom.IsActive {
+			if err := s.evacuate(tx, id, req.TransferToStoreroomID, hotelID, actor, "storeroom deactivated"); err != nil {
+				return err
+			}
+		}
+		if req.IsActive != nil {
+			storeroom.IsActive = *req.IsActive
+		}
+
+		// 本次修改的容量不能低于已存件数（分类容量同理）；未修改容量时不检查，
+		// 已超出容量的寄存室（例如按件数统计后超出）仍可修改名称、位置等
+		usage, err := storedUnits(tx, hotelID, []uint{id})
+		if err != nil {
+			return err
+		}
+		storeroom.ApplyUsage(usage[id])
+		if req.Capacity != nil && storeroom.StoredCount > storeroom.Capacity {
+			return fmt.Errorf("capacity cannot be less than stored count %d", storeroom.StoredCount)
+		}
+		changedSizes := map[string]bool{
+			models.SizeSmall:    req.SmallCapacity != nil,
+			models.SizeLarge:    req.LargeCapacity != nil,
+			models.SizeOversize: req.OversizeCapacity != nil,
+		}
+		for class, remaining := range storeroom.RemainingBySize {
+			if changedSizes[class] && remaining < 0 {
+				return fmt.Errorf("%s_capacity cannot be less than stored %s count %d", class, class, storeroom.StoredBySize[class])
+			}
+		}
+
+		if err := tx.Save(&storeroom).Error; err != nil {
+			return errors.New("update storeroom failed")
+		}
+		record := storeroomAudit(models.AuditStoreroomUpdated, &old, storeroom, "")
+		if len(record.Changes) == 0 {
+			return nil
+		}
+		return recordAudit(tx, hotelID, actor, record)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &storeroom, nil
+}
+
+// DeleteStoreroom 软删除寄存室（同时停用），寄存室内仍有行李时需指定目标寄存室把行李移走
+func (s *StoreroomService) DeleteStoreroom(id uint, req DeleteStoreroomRequest, hotelID uint, actor Actor) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var storeroom models.Storeroom
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND hotel_id = ?", id, hotelID).
+			First(&storeroom).Error; err != nil {
+			return errors.New("invalid storeroom id")
+		}
+
+		if err := s.evacuate(tx, id, req.TransferToStoreroomID, hotelID, actor, "storeroom deleted"); err != nil {
+			return err
+		}
 
-	storeroom.IsActive = req.IsActive
-	if err := database.DB.Save(&storeroom).Error; err != nil {
-		return errors.New("update storeroom status failed")
+		old := storeroom
+		if err := tx.Model(&storeroom).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&storeroom).Error; err != nil {
+			return errors.New("delete storeroom failed")
+		}
+		return recordAudit(tx, hotelID, actor, storeroomAudit(models.AuditStoreroomDeleted, &old, storeroom, ""))
+	})
+}
+
+// evacuate 停用 / 删除前清空寄存室：没有在存行李时直接通过；
+// 有行李且指定了目标寄存室时整体移库，否则返回 ErrStoreroomNotEmpty
+func (s *StoreroomService) evacuate(tx *gorm.DB, id uint, transferTo *uint, hotelID uint, actor Actor, note string) error {
+	usage, err := storedUnits(tx, hotelID, []uint{id})
+	if err != nil {
+		return err
+	}
+	if len(usage[id]) == 0 {
+		return nil
+	}
+	if transferTo == nil {
+		return ErrStoreroomNotEmpty
 	}
+	if *transferTo == id {
+		return ErrSameStoreroom
+	}
+	_, err = NewLuggageService().moveStoreroomLuggage(tx, id, MoveLuggageRequest{
+		ToStoreroomID: *transferTo,
+		Note:          note,
+	}, hotelID, actor)
+	return err
+}
 
-	return nil
+// storeroomAudit 寄存室变更的审计事件，old 为 nil 表示新建
+func storeroomAudit(action string, old *models.Storeroom, storeroom models.Storeroom, note string) auditRecord {
+	var changes models.AuditChanges
+	if old == nil {
+		changes = models.DiffFields(nil, storeroom, storeroomAuditIgnore...)
+	} else {
+		changes = models.DiffFields(*old, storeroom, storeroomAuditIgnore...)
+	}
+	return auditRecord{
+		Action:     action,
+		EntityType: models.AuditEntityStoreroom,
+		EntityID:   storeroom.ID,
+		EntityName: storeroom.Name,
+		Changes:    changes,
+		Note:       note,
+	}
+}
+
+// applySizeCapacity 修改尺寸分类容量：nil 不修改，-1 取消限制
+func applySizeCapacity(dst **int, value *int) {
+	if value == nil {
+		return
+	}
+	if *value == -1 {
+		*dst = nil
+		return
+	}
+	v := *value
+	*dst = &v
 }
 
-func (s *StoreroomService) GetStoreroomOrders(id uint, hotelID uint, status string) ([]models.Luggage, error) {
+func (s *StoreroomService) GetStoreroomOrders(id uint, hotelID uint, status string, filter LuggageFilter, q ListQuery) (*ListPage[models.Luggage], error) {
 	// 验证寄存室是否属于当前酒店
 	var storeroom models.Storeroom
 	if err := database.DB.Where("id = ? AND hotel_id = ?", id, hotelID).First(&storeroom).Error; err != nil {
 		return nil, errors.New("invalid storeroom id")
 	}
 
-	var luggages []models.Luggage
-	query := database.DB.Where("storeroom_id = ?", id)
+	query := database.DB.Model(&models.Luggage{}).Scopes(database.HotelScope(hotelID), filter.Scope).Where("storeroom_id = ?", id)
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
-	if err := query.Find(&luggages).Error; err != nil {
-		return nil, err
+	return paginate(query, hotelID, q, luggageListSpec, luggageSortKey)
+}
+
+// LastMove 行李最近一次移库：来源寄存室、操作人和时间
+type LastMove struct {
+	FromStoreroomID uint
+	MovedBy         string
+	MovedAt         time.Time
+}
+
+// LastMoves 每件行李最近一次移库记录（来自审计事件），没有移库过的行李不在结果中
+func (s *StoreroomService) LastMoves(hotelID uint, luggages []models.Luggage) (map[uint]LastMove, error) {
+	moves := make(map[uint]LastMove)
+	if len(luggages) == 0 {
+		return moves, nil
+	}
+	ids := make([]uint, 0, len(luggages))
+	for _, luggage := range luggages {
+		ids = append(ids, luggage.ID)
 	}
 
-	return luggages, nil
+	var events []models.AuditEvent
+	if err := database.DB.Where("hotel_id = ? AND action = ? AND entity_type = ? AND entity_id IN ?",
+		hotelID, models.AuditLuggageMoved, models.AuditEntityLuggage, ids).
+		Order("id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		from, _ := event.Changes.StoreroomMove()
+		moves[event.EntityID] = LastMove{FromStoreroomID: from, MovedBy: event.Actor, MovedAt: event.CreatedAt}
+	}
+	return moves, nil
 }
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+type TicketService struct {
+	luggageService *LuggageService
+	qrcodeService  *QRCodeService
+}
+
+func NewTicketService() *TicketService {
+	return &TicketService{
+		luggageService: NewLuggageService(),
+		qrcodeService:  NewQRCodeService(),
+	}
+}
+
+// 版面尺寸（单位 mm，A4 纵向）
+const (
+	ticketMargin     = 15.0
+	ticketPageHeight = 297.0
+	ticketStubWidth  = 180.0
+	ticketStubHeight = 85.0
+	ticketCutLineY   = ticketMargin + ticketStubHeight + 10
+	ticketTagWidth   = 87.5
+	ticketTagHeight  = 55.0
+	ticketTagGap     = 5.0
+	ticketFontFamily = "ticket"
+)
+
+// ticketRenderer 封装字体与文本转换，兼容内置字体和外部 UTF-8 字体
+type ticketRenderer struct {
+	pdf    *gofpdf.Fpdf
+	family string
+	tr     func(string) string
+}
+
+func newTicketRenderer() (*ticketRenderer, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(ticketMargin, ticketMargin, ticketMargin)
+
+	r := &ticketRenderer{pdf: pdf}
+	if config.TicketFontPath != "" {
+		// 外部字体直接支持 UTF-8（中文客人姓名等）
+		pdf.AddUTF8Font(ticketFontFamily, "", config.TicketFontPath)
+		pdf.AddUTF8Font(ticketFontFamily, "B", config.TicketFontPath)
+		r.family = ticketFontFamily
+		r.tr = func(s string) string { return s }
+	} else {
+		r.family = "Helvetica"
+		r.tr = pdf.UnicodeTranslatorFromDescriptor("")
+	}
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("load ticket font failed: %w", err)
+	}
+	return r, nil
+}
+
+// text 在指定位置输出单行文本，超出宽度时截断并追加省略号
+func (r *ticketRenderer) text(x, y, w, h float64, style string, size float64, s string) {
+	r.pdf.SetFont(r.family, style, size)
+	out := r.tr(s)
+	if r.pdf.GetStringWidth(out) > w {
+		// 按原始 UTF-8 字符截断，避免截断转换后的单字节编码
+		runes := []rune(s)
+		for len(runes) > 0 && r.pdf.GetStringWidth(r.tr(string(runes)+"...")) > w {
+			runes = runes[:len(runes)-1]
+		}
+		out = r.tr(string(runes) + "...")
+	}
+	r.pdf.SetXY(x, y)
+	r.pdf.CellFormat(w, h, out, "", 0, "L", false, 0, "")
+}
+
+// GenerateClaimTicket 根据取件码生成寄存凭条 PDF：
+// 第一部分为客人联（客人姓名、寄存室、件数、寄存时间、取件码及二维码），
+// 裁切线以下为每条行李记录对应的一张行李标签。同时返回规范化后的取件码，供下载文件名使用
+func (s *TicketService) GenerateClaimTicket(code string, hotelID uint) ([]byte, string, error) {
+	code = normalizeRetrievalCode(code)
+	luggages, err := s.luggageService.GetLuggageByCode(code, hotelID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 只为在存的行李出票
+	stored := make([]models.Luggage, 0, len(luggages))
+	for _, l := range luggages {
+		if models.IsInStorage(l.Status) {
+			stored = append(stored, l)
+		}
+	}
+	if len(stored) == 0 {
+		return nil, "", errors.New("no stored luggage found for this code in this hotel")
+	}
+
+	// 加载涉及的寄存室（多件模式下可能分布在不同寄存室）
+	storeroomIDs := make([]uint, 0, len(stored))
+	for _, l := range stored {
+		storeroomIDs = append(storeroomIDs, l.StoreroomID)
+	}
+	var storerooms []models.Storeroom
+	if err := database.DB.Where("id IN ? AND hotel_id = ?", storeroomIDs, hotelID).Find(&storerooms).Error; err != nil {
+		return nil, "", err
+	}
+	storeroomByID := make(map[uint]models.Storeroom, len(storerooms))
+	for _, room := range storerooms {
+		storeroomByID[room.ID] = room
+	}
+
+	hotel, err := NewHotelService().GetHotel(hotelID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pdf, err := s.renderClaimTicket(code, stored, storeroomByID, hotel.Location())
+	return pdf, code, err
+}
+
+// renderClaimTicket 排版并输出 PDF，时间按酒店时区显示
+func (s *TicketService) renderClaimTicket(code string, stored []models.Luggage, storeroomByID map[uint]models.Storeroom, loc *time.Location) ([]byte, error) {
+	r, err := newTicketRenderer()
+	if err != nil {
+		return nil, err
+	}
+
+	qrPNG, _, err := s.qrcodeService.GenerateQRCode(code, QRCodeOptions{Format: QRCodeFormatPNG, Size: 512, Level: "M"})
+	if err != nil {
+		return nil, err
+	}
+	r.pdf.RegisterImageOptionsReader("qrcode", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(qrPNG))
+
+	r.pdf.AddPage()
+	s.drawStub(r, code, stored, storeroomByID, loc)
+
+	// 裁切线
+	r.pdf.SetDashPattern([]float64{2, 2}, 0)
+	r.pdf.Line(ticketMargin-5, ticketCutLineY, ticketMargin+ticketStubWidth+5, ticketCutLineY)
+	r.pdf.SetDashPattern([]float64{}, 0)
+
+	// 行李标签：每页两列，从裁切线下方开始排布，排满后换页
+	y := ticketCutLineY + ticketTagGap
+	for i, l := range stored {
+		col := i % 2
+		if col == 0 && y+ticketTagHeight > ticketPageHeight-ticketMargin {
+			r.pdf.AddPage()
+			y = ticketMargin
+		}
+		x := ticketMargin + float64(col)*(ticketTagWidth+ticketTagGap)
+		s.drawTag(r, x, y, code, i+1, len(stored), l, storeroomByID[l.StoreroomID], loc)
+		if col == 1 {
+			y += ticketTagHeight + ticketTagGap
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := r.pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawStub 绘制客人联
+func (s *TicketService) drawStub(r *ticketRenderer, code string, luggages []models.Luggage, storeroomByID map[uint]models.Storeroom, loc *time.Location) {
+	x, y := ticketMargin, ticketMargin
+	r.pdf.Rect(x, y, ticketStubWidth, ticketStubHeight, "D")
+
+	totalQuantity := 0
+	var rooms []string
+	seen := make(map[uint]bool)
+	for _, l := range luggages {
+		totalQuantity += l.Quantity
+		if seen[l.StoreroomID] {
+			continue
+		}
+		seen[l.StoreroomID] = true
+		rooms = append(rooms, formatStoreroom(storeroomByID[l.StoreroomID]))
+	}
+
+	textWidth := ticketStubWidth - 70
+	r.text(x+5, y+5, textWidth, 8, "B", 16, "LUGGAGE CLAIM TICKET")
+	r.text(x+5, y+17, textWidth, 6, "", 11, "Guest: "+guestLabel(luggages[0]))
+	r.text(x+5, y+24, textWidth, 6, "", 11, "Storeroom: "+strings.Join(rooms, "; "))
+	r.text(x+5, y+31, textWidth, 6, "", 11, fmt.Sprintf("Items: %d (%d bags)", len(luggages), totalQuantity))
+	r.text(x+5, y+38, textWidth, 6, "", 11, "Stored at: "+luggages[0].StoredAt.In(loc).Format("2006-01-02 15:04"))
+	r.text(x+5, y+48, textWidth, 6, "", 10, "Retrieval code:")
+	r.text(x+5, y+55, textWidth, 14, "B", 30, code)
+	r.text(x+5, y+74, textWidth, 5, "", 8, "Please present this ticket when collecting your luggage.")
+
+	r.pdf.ImageOptions("qrcode", x+ticketStubWidth-62, y+12, 55, 55, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+}
+
+// drawTag 绘制单件行李标签
+func (s *TicketService) drawTag(r *ticketRenderer, x, y float64, code string, index, total int, l models.Luggage, storeroom models.Storeroom, loc *time.Location) {
+	r.pdf.Rect(x, y, ticketTagWidth, ticketTagHeight, "D")
+
+	textWidth := ticketTagWidth - 40
+	r.text(x+4, y+4, textWidth, 6, "B", 10, fmt.Sprintf("BAG TAG %d/%d", index, total))
+	r.text(x+4, y+11, textWidth, 10, "B", 20, code)
+	r.text(x+4, y+23, textWidth, 5, "", 9, "Guest: "+guestLabel(l))
+	r.text(x+4, y+29, textWidth, 5, "", 9, "Room: "+formatStoreroom(storeroom))
+	r.text(x+4, y+35, textWidth, 5, "", 9, fmt.Sprintf("Qty: %d", l.Quantity)+formatSlot(l))
+	r.text(x+4, y+41, ticketTagWidth-8, 5, "", 9, l.Description)
+	r.text(x+4, y+47, ticketTagWidth-8, 5, "", 7, fmt.Sprintf("#%d  %s", l.ID, l.StoredAt.In(loc).Format("2006-01-02 15:04")))
+
+	r.pdf.ImageOptions("qrcode", x+ticketTagWidth-35, y+4, 31, 31, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+}
+
+// guestLabel 客人姓名，登记了房号时附带房号
+func guestLabel(l models.Luggage) string {
+	if l.RoomNumber == "" {
+		return l.GuestName
+	}
+	return l.GuestName + " (Rm " + l.RoomNumber + ")"
+}
+
+// formatSlot 行李所在格位，未分配格位时为空
+func formatSlot(l models.Luggage) string {
+	if l.SlotCode == "" {
+		return ""
+	}
+	return "   Slot: " + l.SlotCode
+}
+
+func formatStoreroom(room models.Storeroom) string {
+	if room.Location == "" {
+		return room.Name
+	}
+	return room.Name + " (" + room.Location + ")"
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"luggage-sys2/internal/database"
+	"luggage-sys2/internal/models"
+	"luggage-sys2/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+type UserService struct{}
+
+func NewUserService() *UserService {
+	return &UserService{}
+}
+
+const minPasswordLength = 6
+
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"` // 默认 staff
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UpdateUserStatusRequest struct {
+	IsActive bool `json:"is_active"`
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// validatePassword 校验密码强度
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// validateRole 校验角色是否合法
+func validateRole(role string) error {
+	switch role {
+	case models.RoleAdmin, models.RoleManager, models.RoleStaff:
+		return nil
+	default:
+		return fmt.Errorf("invalid role '%s', expected admin, manager or staff", role)
+	}
+}
+
+// getHotelUser 获取当前酒店下的用户
+func (s *UserService) getHotelUser(id uint, hotelID uint) (*models.User, error) {
+	var user models.User
+	if err := database.DB.Where("id = ? AND hotel_id = ?", id, hotelID).First(&user).Error; err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return &user, nil
+}
+
+func (s *UserService) ListUsers(hotelID uint) ([]models.User, error) {
+	var users []models.User
+	if err := database.DB.Where("hotel_id = ?", hotelID).Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// CreateUser 为管理员所在酒店创建账号，新账号首次登录须修改密码
+func (s *UserService) CreateUser(req CreateUserRequest, hotelID uint) (*models.User, error) {
+	if req.Role == "" {
+		req.Role = models.RoleStaff
+	}
+	if err := validateRole(req.Role); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("username = ?", req.Username).Count(&count)
+	if count > 0 {
+		return nil, errors.New("username already exists")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:           req.Username,
+		Password:           hashedPassword,
+		Role:               req.Role,
+		HotelID:            hotelID,
+		IsActive:           true,
+		MustChangePassword: true,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, errors.New("create user failed")
+	}
+
+	return &user, nil
+}
+
+// UpdateUserRole 修改角色，管理员不能修改自己的角色，避免酒店失去管理员。
+// 角色记录在 access token 中，修改后同时吊销该用户的会话，旧 token 立即失效，重新登录后按新角色授权
+func (s *UserService) UpdateUserRole(id uint, req UpdateUserRoleRequest, hotelID uint, operatorID uint) error {
+	if err := validateRole(req.Role); err != nil {
+		return err
+	}
+	if id == operatorID {
+		return errors.New("cannot change your own role")
+	}
+
+	user, err := s.getHotelUser(id, hotelID)
+	if err != nil {
+		return err
+	}
+	if user.Role == req.Role {
+		return nil
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("role", req.Role).Error; err != nil {
+			return errors.New("update user role failed")
+		}
+		_, err := revokeUserSessions(tx, user.ID, "")
+		return err
+	})
+}
+
+// UpdateUserStatus 启用/停用账号
+func (s *UserService) UpdateUserStatus(id uint, req UpdateUserStatusRequest, hotelID uint, operatorID uint) error {
+	if id == operatorID && !req.IsActive {
+		return errors.New("cannot disable your own account")
+	}
+
+	user, err := s.getHotelUser(id, hotelID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("is_active", req.IsActive).Error; err != nil {
+			return errors.New("update user status failed")
+		}
+
+		// 停用账号时立即让已登录的设备下线
+		if !req.IsActive {
+			if _, err := revokeUserSessions(tx, user.ID, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ResetPassword 管理员重置密码，用户下次登录后须修改密码
+func (s *UserService) ResetPassword(id uint, req ResetPasswordRequest, hotelID uint) error {
+	if err := validatePassword(req.Password); err != nil {
+		return err
+	}
+
+	user, err := s.getHotelUser(id, hotelID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":             hashedPassword,
+			"must_change_password": true,
+		}).Error; err != nil {
+			return errors.New("reset password failed")
+		}
+
+		_, err := revokeUserSessions(tx, user.ID, "")
+		return err
+	})
+}
+
+// RevokeUserSessions 强制用户所有设备下线
+func (s *UserService) RevokeUserSessions(id uint, hotelID uint) (int64, error) {
+	user, err := s.getHotelUser(id, hotelID)
+	if err != nil {
+		return 0, err
+	}
+	return NewSessionService().RevokeUserSessions(user.ID, "")
+}
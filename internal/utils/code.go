@@ -1,19 +1,82 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
 )
 
-const digitCharset = "0123456789"
+// DigitAlphabet 纯数字取件码字符集
+const DigitAlphabet = "0123456789"
 
-// GenerateRetrievalCode 生成6位随机数字取件码
-func GenerateRetrievalCode() string {
-	// 使用独立的随机数生成器，避免并发问题
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, 6)
+// UnambiguousAlphabet 去掉易混淆字符（0/O、1/I/L）的数字字母字符集
+const UnambiguousAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// GenerateRetrievalCode 使用 crypto/rand 从 alphabet 中生成取件码，
+// length 为随机字符数，末尾另加一位校验位（Luhn mod N，总长度 length+1），用于发现输错的取件码
+func GenerateRetrievalCode(alphabet string, length int) (string, error) {
+	if len(alphabet) < 2 || length < 1 {
+		return "", errors.New("invalid retrieval code alphabet or length")
+	}
+
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, length, length+1)
 	for i := range b {
-		b[i] = digitCharset[r.Intn(len(digitCharset))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[n.Int64()]
+	}
+
+	check, ok := checkCharacter(string(b), alphabet)
+	if !ok {
+		return "", errors.New("invalid retrieval code alphabet")
+	}
+	return string(append(b, check)), nil
+}
+
+// GenerateNumericPIN 使用 crypto/rand 生成指定位数的数字 PIN
+func GenerateNumericPIN(length int) (string, error) {
+	max := big.NewInt(int64(len(DigitAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = DigitAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// ValidRetrievalCodeCheck 校验取件码最后一位校验位是否正确
+func ValidRetrievalCodeCheck(code, alphabet string) bool {
+	if len(code) < 2 {
+		return false
+	}
+	check, ok := checkCharacter(code[:len(code)-1], alphabet)
+	return ok && check == code[len(code)-1]
+}
+
+// checkCharacter 按 Luhn mod N 算法计算校验字符，body 含字符集以外的字符时返回 false
+func checkCharacter(body, alphabet string) (byte, bool) {
+	n := len(alphabet)
+	factor := 2
+	sum := 0
+	for i := len(body) - 1; i >= 0; i-- {
+		codePoint := strings.IndexByte(alphabet, body[i])
+		if codePoint < 0 {
+			return 0, false
+		}
+		addend := factor * codePoint
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+		sum += addend/n + addend%n
 	}
-	return string(b)
+	return alphabet[(n-sum%n)%n], true
 }
@@ -5,23 +5,32 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"luggage-sys2/internal/config"
+	"luggage-sys2/internal/models"
 )
 
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	HotelID  uint   `json:"hotel_id"`
+	Role     string `json:"role"`
+	// MustChangePassword 为 true 时只允许调用修改密码接口
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// SessionID 登录会话 ID，用于服务端吊销
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(userID uint, username string, hotelID uint) (string, error) {
+// GenerateToken 生成JWT access token，绑定到登录会话
+func GenerateToken(user *models.User, sessionID string) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		HotelID:  hotelID,
+		UserID:             user.ID,
+		Username:           user.Username,
+		HotelID:            user.HotelID,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		SessionID:          sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -3,19 +3,41 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 
 	"luggage-sys2/internal/config"
 	"luggage-sys2/internal/database"
 	"luggage-sys2/internal/routes"
+	"luggage-sys2/internal/services"
 )
 
 func main() {
 	// 初始化配置
 	config.Init()
 
+	// 校验审计哈希链后退出：luggage-sys2 verify-audit [hotel_id ...]
+	// 只连接数据库，不做迁移和补链，校验前不改动任何数据
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		database.Connect()
+		os.Exit(verifyAudit(os.Args[2:]))
+	}
+
 	// 初始化数据库
 	database.Init()
 
+	// 启动数据保留清理任务
+	services.StartRetentionJob()
+
+	// 启动客人领取提醒任务
+	services.StartReminderJob()
+
+	// 启动逾期行李检查任务
+	services.StartOverdueJob()
+
+	// 启动审计哈希链签名检查点任务
+	services.StartAuditCheckpointJob()
+
 	// 设置路由
 	r := routes.SetupRoutes()
 
@@ -28,3 +50,45 @@ func main() {
 	}
 }
 
+// verifyAudit 校验指定酒店（未指定时为全部酒店）的审计哈希链，输出每个酒店的结果；
+// 全部通过返回 0，有断开返回 1，参数或查询出错返回 2
+func verifyAudit(args []string) int {
+	auditService := services.NewAuditService()
+
+	var hotelIDs []uint
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid hotel_id %q\n", arg)
+			return 2
+		}
+		hotelIDs = append(hotelIDs, uint(id))
+	}
+	if len(hotelIDs) == 0 {
+		var err error
+		if hotelIDs, err = auditService.ChainHotelIDs(); err != nil {
+			fmt.Fprintf(os.Stderr, "list audit chains failed: %v\n", err)
+			return 2
+		}
+	}
+
+	code := 0
+	for _, hotelID := range hotelIDs {
+		report, err := auditService.VerifyChain(hotelID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hotel %d: verify failed: %v\n", hotelID, err)
+			return 2
+		}
+		if report.Valid {
+			fmt.Printf("hotel %d: ok, %d events, head seq %d, %d checkpoints\n",
+				hotelID, report.Checked, report.HeadSeq, report.Checkpoints)
+			continue
+		}
+		fmt.Printf("hotel %d: BROKEN at seq %d (event %d): %s\n",
+			hotelID, report.Break.Seq, report.Break.EventID, report.Break.Reason)
+		code = 1
+	}
+	return code
+}
+
+